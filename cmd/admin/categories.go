@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// findCategory loads a category of the interest tree by slug
+func findCategory(ctx context.Context, slug string) (*models.InterestCategory, error) {
+	var category models.InterestCategory
+	err := database.DB.Collection("interest_categories").FindOne(ctx, bson.M{"slug": slug}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("no interest category with slug %q", slug)
+	}
+	return &category, err
+}
+
+func listCategories(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("categories list", flag.ContinueOnError)
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := database.DB.Collection("interest_categories").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var categories []models.InterestCategory
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, nil, err
+	}
+	byParent := map[primitive.ObjectID][]models.InterestCategory{}
+	for _, category := range categories {
+		parent := primitive.NilObjectID
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		byParent[parent] = append(byParent[parent], category)
+	}
+	tbl := &table{headers: []string{"SLUG", "NAME", "ORDER", "ID"}}
+	var walk func(parent primitive.ObjectID, depth int)
+	walk = func(parent primitive.ObjectID, depth int) {
+		for _, category := range byParent[parent] {
+			tbl.add(strings.Repeat("  ", depth)+category.Slug, category.Name, strconv.Itoa(category.Order), category.ID.Hex())
+			walk(category.ID, depth+1)
+		}
+	}
+	walk(primitive.NilObjectID, 0)
+	return categories, tbl, nil
+}
+
+func addCategory(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("categories add", flag.ContinueOnError)
+	parentSlug := fs.String("parent", "", "slug of the parent category")
+	slug := fs.String("slug", "", "slug, derived from the name by default")
+	order := fs.Int("order", 0, "display order among siblings")
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	category := models.InterestCategory{Name: strings.TrimSpace(pos[0]), Slug: *slug, Path: []primitive.ObjectID{}, Order: *order}
+	if category.Name == "" {
+		return nil, nil, errors.New("category name cannot be empty")
+	}
+	if category.Slug == "" {
+		category.Slug = utils.Slugify(category.Name)
+	}
+	if category.Slug == "" || category.Slug != utils.Slugify(category.Slug) {
+		return nil, nil, errors.New("slug must be lowercase letters and digits separated by dashes")
+	}
+	if *parentSlug != "" {
+		parent, err := findCategory(ctx, *parentSlug)
+		if err != nil {
+			return nil, nil, err
+		}
+		category.ParentID = &parent.ID
+		category.Path = append(parent.Path, parent.ID)
+	}
+	res, err := database.DB.Collection("interest_categories").InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, nil, fmt.Errorf("a category with slug %q already exists", category.Slug)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return message("added category %s (%s)", category.Slug, res.InsertedID.(primitive.ObjectID).Hex())
+}
+
+// reparent moves category (and so its subtree) under parent, or to the top level if parent is nil
+func reparent(ctx context.Context, category *models.InterestCategory, parent *models.InterestCategory) error {
+	path := []primitive.ObjectID{}
+	var parentID *primitive.ObjectID
+	if parent != nil {
+		if parent.ID == category.ID || containsID(parent.Path, category.ID) {
+			return errors.New("cannot move a category under itself or one of its descendants")
+		}
+		path = append(append(path, parent.Path...), parent.ID)
+		parentID = &parent.ID
+	}
+	categories := database.DB.Collection("interest_categories")
+	update := bson.M{"$set": bson.M{"parent_id": parentID, "path": path}}
+	if parentID == nil {
+		update = bson.M{"$set": bson.M{"path": path}, "$unset": bson.M{"parent_id": ""}}
+	}
+	if _, err := categories.UpdateByID(ctx, category.ID, update); err != nil {
+		return err
+	}
+
+	// descendants keep their path below the category and take its new path above it
+	cursor, err := categories.Find(ctx, bson.M{"path": category.ID})
+	if err != nil {
+		return err
+	}
+	var descendants []models.InterestCategory
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return err
+	}
+	var updates []mongo.WriteModel
+	for _, d := range descendants {
+		for i, id := range d.Path {
+			if id == category.ID {
+				newPath := append(append(append([]primitive.ObjectID{}, path...), category.ID), d.Path[i+1:]...)
+				updates = append(updates, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": d.ID}).SetUpdate(bson.M{"$set": bson.M{"path": newPath}}))
+				break
+			}
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	_, err = categories.BulkWrite(ctx, updates)
+	return err
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func moveCategory(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("categories move", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	category, err := findCategory(ctx, pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	var parent *models.InterestCategory
+	if pos[1] != "-" {
+		if parent, err = findCategory(ctx, pos[1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := reparent(ctx, category, parent); err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		return message("moved %s to the top level", category.Slug)
+	}
+	return message("moved %s under %s", category.Slug, parent.Slug)
+}
+
+// mergeCategories files the interests and subcategories of one category under another and deletes the first
+func mergeCategories(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("categories merge", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	from, err := findCategory(ctx, pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	into, err := findCategory(ctx, pos[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	if from.ID == into.ID || containsID(into.Path, from.ID) {
+		return nil, nil, errors.New("cannot merge a category into itself or one of its descendants")
+	}
+
+	cursor, err := database.DB.Collection("interest_categories").Find(ctx, bson.M{"parent_id": from.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	var children []models.InterestCategory
+	if err := cursor.All(ctx, &children); err != nil {
+		return nil, nil, err
+	}
+	for i := range children {
+		if err := reparent(ctx, &children[i], into); err != nil {
+			return nil, nil, err
+		}
+	}
+	moved, err := database.DB.Collection("interests").UpdateMany(ctx, bson.M{"category_id": from.ID}, bson.M{
+		"$set": bson.M{"category_id": into.ID, "category": into.Name},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := database.DB.Collection("interest_categories").DeleteOne(ctx, bson.M{"_id": from.ID}); err != nil {
+		return nil, nil, err
+	}
+	return message("merged %s into %s: %d interests and %d subcategories moved", from.Slug, into.Slug, moved.ModifiedCount, len(children))
+}
+
+func categorizeInterest(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("interests categorize", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	interestID, err := objectID("interest", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	category, err := findCategory(ctx, pos[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := database.DB.Collection("interests").UpdateByID(ctx, interestID, bson.M{
+		"$set": bson.M{"category_id": category.ID, "category": category.Name},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return message("interest %s filed under %s", interestID.Hex(), category.Slug)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+
+	"fast-af/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The API server caches the interest catalog in memory for up to a minute, and clients
+// may keep it as long again (Cache-Control), so edits made here show up within two minutes.
+
+func renameInterest(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("interests rename", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	interestID, err := objectID("interest", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	name := strings.TrimSpace(pos[1])
+	if name == "" {
+		return nil, nil, errors.New("interest name cannot be empty")
+	}
+
+	interests := database.DB.Collection("interests")
+	count, err := interests.CountDocuments(ctx, bson.M{"name": name, "_id": bson.M{"$ne": interestID}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if count > 0 {
+		return nil, nil, errors.New("an interest with this name already exists; merge instead")
+	}
+	res, err := interests.UpdateByID(ctx, interestID, bson.M{"$set": bson.M{"name": name}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return message("interest %s renamed to %q", interestID.Hex(), name)
+}
+
+// mergeInterests moves every user from one interest to another and deletes the first
+func mergeInterests(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("interests merge", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromID, err := objectID("interest", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	intoID, err := objectID("interest", pos[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	if fromID == intoID {
+		return nil, nil, errors.New("cannot merge an interest into itself")
+	}
+
+	interests := database.DB.Collection("interests")
+	for _, id := range []interface{}{fromID, intoID} {
+		if err := interests.FindOne(ctx, bson.M{"_id": id}).Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	userInterests := database.DB.Collection("user_interests")
+	// users that already have the target interest just lose the merged one
+	haveTarget, err := userInterests.Distinct(ctx, "user_id", bson.M{"interest_id": intoID})
+	if err != nil {
+		return nil, nil, err
+	}
+	removed, err := userInterests.DeleteMany(ctx, bson.M{"interest_id": fromID, "user_id": bson.M{"$in": haveTarget}})
+	if err != nil {
+		return nil, nil, err
+	}
+	moved, err := userInterests.UpdateMany(ctx, bson.M{"interest_id": fromID}, bson.M{"$set": bson.M{"interest_id": intoID}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := interests.DeleteOne(ctx, bson.M{"_id": fromID}); err != nil {
+		return nil, nil, err
+	}
+	return message("merged %s into %s: %d users moved, %d duplicates dropped", fromID.Hex(), intoID.Hex(), moved.ModifiedCount, removed.DeletedCount)
+}
@@ -0,0 +1,177 @@
+// Command admin operates on the fast-af database: users, photos, interests and their
+// categories, proximity sessions, meeting requests, chat transcripts and trust scores.
+//
+//	go run ./cmd/admin [-o table|json] <resource> <action> [flags] [args]
+//
+// It reads the same .env as the API server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const usage = `usage: admin [-o table|json] <command> [flags] [args]
+
+commands:
+  users list [-limit N]
+  users search [-limit N] <text>             match name or email
+  users suspend [-for 72h | -until YYYY-MM-DD] [-reason text] <userId>
+  users ban [-reason text] <userId>
+  users unsuspend <userId>                   lifts a suspension or a ban
+  users role <userId> <role>                 user, moderator or admin; moderators work the report queue
+  users set-dob <userId> <YYYY-MM-DD>        users cannot change their date of birth once set
+  users delete <userId>                      also removes the user's interests, availabilities, sessions and photo files
+  photos pending [-limit N]                  photos awaiting moderation
+  photos approve <userId> <photoId>
+  photos reject <userId> <photoId>
+  interests rename <interestId> <new name>
+  interests merge <fromInterestId> <intoInterestId>
+  interests categorize <interestId> <categorySlug>
+  categories list                            the interest category tree
+  categories add [-parent slug] [-slug s] [-order N] <name>
+  categories move <slug> <parentSlug | ->    "-" moves to the top level
+  categories merge <fromSlug> <intoSlug>     moves interests and subcategories, then deletes the first
+  proximity expire [-user userId]            expire one user's or every active session
+  meetings show <meetingRequestId>
+  meetings list -user userId
+  meetings cancel <meetingRequestId>
+  chat transcript <chatWindowId>
+  trust recompute [-user userId]             from ratings, reports and moderation history
+`
+
+// command is one "<resource> <action>" entry point
+type command func(ctx context.Context, args []string) (interface{}, *table, error)
+
+var commands = map[string]command{
+	"users list":           listUsers,
+	"users search":         searchUsers,
+	"users suspend":        suspendUser,
+	"users ban":            banUser,
+	"users unsuspend":      unsuspendUser,
+	"users role":           setUserRole,
+	"users set-dob":        setUserDateOfBirth,
+	"users delete":         deleteUser,
+	"photos pending":       pendingPhotos,
+	"photos approve":       approvePhoto,
+	"photos reject":        rejectPhoto,
+	"interests rename":     renameInterest,
+	"interests merge":      mergeInterests,
+	"interests categorize": categorizeInterest,
+	"categories list":      listCategories,
+	"categories add":       addCategory,
+	"categories move":      moveCategory,
+	"categories merge":     mergeCategories,
+	"proximity expire":     expireProximities,
+	"meetings show":        showMeetingRequest,
+	"meetings list":        listMeetingRequests,
+	"meetings cancel":      cancelMeetingRequest,
+	"chat transcript":      chatTranscript,
+	"trust recompute":      recomputeTrustScores,
+}
+
+func main() {
+	output := flag.String("o", "table", "output format: table or json")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]+" "+args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0]+" "+args[1])
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintln(os.Stderr, "-o must be table or json")
+		os.Exit(2)
+	}
+
+	config.LoadConfig()
+	database.ConnectMongo()
+	storage.Setup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result, tbl, err := cmd(ctx, args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if *output == "json" || tbl == nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		return
+	}
+	tbl.print()
+}
+
+// table is the human readable rendering of a command result
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// message wraps the result of a mutating command
+func message(format string, a ...interface{}) (interface{}, *table, error) {
+	msg := fmt.Sprintf(format, a...)
+	return map[string]string{"message": msg}, &table{headers: []string{"RESULT"}, rows: [][]string{{msg}}}, nil
+}
+
+// parseFlags parses a subcommand's flags and checks its positional argument count
+func parseFlags(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != positional {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", fs.Name(), positional, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func objectID(kind string, hex string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return oid, fmt.Errorf("invalid %s id %q", kind, hex)
+	}
+	return oid, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"fast-af/database"
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// pendingPhoto is one gallery photo awaiting moderation
+type pendingPhoto struct {
+	UserID     string    `bson:"user_id" json:"userId"`
+	Name       string    `bson:"name" json:"name"`
+	PhotoID    string    `bson:"photo_id" json:"photoId"`
+	Key        string    `bson:"key" json:"key"`
+	UploadedAt time.Time `bson:"uploaded_at" json:"uploadedAt"`
+}
+
+func pendingPhotos(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("photos pending", flag.ContinueOnError)
+	limit := fs.Int64("limit", 50, "maximum number of photos")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"photos.moderation_status": models.PhotoPending}}},
+		{{Key: "$unwind", Value: "$photos"}},
+		{{Key: "$match", Value: bson.M{"photos.moderation_status": models.PhotoPending}}},
+		{{Key: "$sort", Value: bson.M{"photos.uploaded_at": 1}}},
+		{{Key: "$limit", Value: *limit}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"user_id":     bson.M{"$toString": "$_id"},
+			"name":        1,
+			"photo_id":    bson.M{"$toString": "$photos._id"},
+			"key":         "$photos.key",
+			"uploaded_at": "$photos.uploaded_at",
+		}}},
+	}
+	cursor, err := database.DB.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	photos := []pendingPhoto{}
+	if err := cursor.All(ctx, &photos); err != nil {
+		return nil, nil, err
+	}
+
+	t := &table{headers: []string{"USER", "NAME", "PHOTO", "KEY", "UPLOADED"}}
+	for _, p := range photos {
+		t.add(p.UserID, p.Name, p.PhotoID, p.Key, formatTime(p.UploadedAt))
+	}
+	return photos, t, nil
+}
+
+func approvePhoto(ctx context.Context, args []string) (interface{}, *table, error) {
+	return moderatePhoto(ctx, "photos approve", args, models.PhotoApproved)
+}
+
+func rejectPhoto(ctx context.Context, args []string) (interface{}, *table, error) {
+	return moderatePhoto(ctx, "photos reject", args, models.PhotoRejected)
+}
+
+func moderatePhoto(ctx context.Context, name string, args []string, status string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	photoID, err := objectID("photo", pos[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID, "photos._id": photoID},
+		bson.M{
+			"$set": bson.M{"photos.$.moderation_status": status, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return message("photo %s %s", photoID.Hex(), status)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"fast-af/controllers"
+	"fast-af/database"
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func expireProximities(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("proximity expire", flag.ContinueOnError)
+	user := fs.String("user", "", "only expire this user's session")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+	filter := bson.M{"expires_at": bson.M{"$gt": time.Now()}}
+	if *user != "" {
+		userID, err := objectID("user", *user)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter["user_id"] = userID
+	}
+	res, err := database.DB.Collection("active_proximities").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"expires_at": time.Now()}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return message("%d proximity sessions expired", res.ModifiedCount)
+}
+
+func meetingRequestsTable(requests []models.MeetingRequest) *table {
+	t := &table{headers: []string{"ID", "REQUESTER", "TARGET", "AVAILABILITY", "STATUS", "VERSION", "CREATED", "MESSAGE"}}
+	for _, r := range requests {
+		t.add(r.ID.Hex(), r.RequesterID.Hex(), r.TargetUserID.Hex(), r.AvailabilityID.Hex(), r.Status,
+			fmt.Sprint(r.Version), formatTime(r.CreatedAt), r.Message)
+	}
+	return t
+}
+
+func showMeetingRequest(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("meetings show", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqID, err := objectID("meeting request", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	var req models.MeetingRequest
+	if err := database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqID}).Decode(&req); err != nil {
+		return nil, nil, err
+	}
+	return req, meetingRequestsTable([]models.MeetingRequest{req}), nil
+}
+
+func listMeetingRequests(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("meetings list", flag.ContinueOnError)
+	user := fs.String("user", "", "user that sent or received the requests")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", *user)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter := bson.M{"$or": bson.A{bson.M{"requester_id": userID}, bson.M{"target_user_id": userID}}}
+	cursor, err := database.DB.Collection("meeting_requests").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	requests := []models.MeetingRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, nil, err
+	}
+	return requests, meetingRequestsTable(requests), nil
+}
+
+// cancelMeetingRequest marks a request deleted the same way a requester's cancel does
+func cancelMeetingRequest(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("meetings cancel", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqID, err := objectID("meeting request", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	update := bson.M{"$set": bson.M{"status": "deleted", "updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+	res, err := database.DB.Collection("meeting_requests").UpdateOne(ctx, bson.M{"_id": reqID, "status": bson.M{"$ne": "deleted"}}, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, errors.New("meeting request not found or already cancelled")
+	}
+	return message("meeting request %s cancelled", reqID.Hex())
+}
+
+func chatTranscript(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("chat transcript", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	windowID, err := objectID("chat window", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var window models.ChatWindow
+	if err := database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": windowID}).Decode(&window); err != nil {
+		return nil, nil, err
+	}
+	cursor, err := database.DB.Collection("chats").Find(ctx, bson.M{"chat_window_id": windowID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := []models.Chat{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, nil, err
+	}
+
+	// resolve participant names for the table view
+	names := map[primitive.ObjectID]string{}
+	users, err := findUsers(ctx, bson.M{"_id": bson.M{"$in": window.ParticipantIDs}}, int64(len(window.ParticipantIDs)))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+
+	t := &table{headers: []string{"TIME", "FROM", "MESSAGE"}}
+	for _, m := range messages {
+		from := names[m.CreatedBy]
+		if from == "" {
+			from = m.CreatedBy.Hex()
+		}
+		t.add(formatTime(m.CreatedAt), from, m.Msg)
+	}
+	return map[string]interface{}{"chatWindow": window, "messages": messages}, t, nil
+}
+
+func recomputeTrustScores(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("trust recompute", flag.ContinueOnError)
+	user := fs.String("user", "", "only recompute this user's score")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+	filter := bson.M{}
+	if *user != "" {
+		userID, err := objectID("user", *user)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter["_id"] = userID
+	}
+	modified, err := controllers.RecomputeTrustScores(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return message("%d trust scores updated", modified)
+}
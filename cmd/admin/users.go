@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"fast-af/config"
+	"fast-af/controllers"
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/storage"
+	"fast-af/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func usersTable(users []models.User) *table {
+	t := &table{headers: []string{"ID", "NAME", "EMAIL", "STATUS", "TRUST", "RATED", "VERIFIED", "CREATED"}}
+	for _, u := range users {
+		status := u.Status
+		if status == "" {
+			status = models.UserStatusActive
+		}
+		if u.SuspendedUntil != nil {
+			status += " until " + formatTime(*u.SuspendedUntil)
+		}
+		t.add(u.ID.Hex(), u.Name, u.Email, status, strconv.FormatFloat(u.TrustScore, 'f', 2, 64),
+			strconv.Itoa(u.UsersRated), strconv.FormatBool(u.Verified), formatTime(u.CreatedAt))
+	}
+	return t
+}
+
+func findUsers(ctx context.Context, filter bson.M, limit int64) ([]models.User, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := database.DB.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func listUsers(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	limit := fs.Int64("limit", 50, "maximum number of users")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, nil, err
+	}
+	users, err := findUsers(ctx, bson.M{}, *limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, usersTable(users), nil
+}
+
+func searchUsers(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users search", flag.ContinueOnError)
+	limit := fs.Int64("limit", 50, "maximum number of users")
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	pattern := literalRegex(pos[0])
+	users, err := findUsers(ctx, bson.M{"$or": bson.A{bson.M{"name": pattern}, bson.M{"email": pattern}}}, *limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, usersTable(users), nil
+}
+
+// literalRegex matches text literally and case-insensitively
+func literalRegex(text string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"}
+}
+
+func suspendUser(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users suspend", flag.ContinueOnError)
+	duration := fs.Duration("for", 0, "suspension length, e.g. 72h")
+	until := fs.String("until", "", "suspension end date, YYYY-MM-DD")
+	reason := fs.String("reason", "", "reason shown to moderators")
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var end time.Time
+	switch {
+	case *duration > 0 && *until != "":
+		return nil, nil, errors.New("use either -for or -until")
+	case *duration > 0:
+		end = time.Now().Add(*duration)
+	case *until != "":
+		end, err = time.ParseInLocation("2006-01-02", *until, time.Local)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid -until: %v", err)
+		}
+	default:
+		return nil, nil, errors.New("-for or -until is required")
+	}
+
+	if err := controllers.SuspendUser(ctx, userID, end, *reason); err != nil {
+		return nil, nil, err
+	}
+	return message("user %s suspended until %s", userID.Hex(), formatTime(end))
+}
+
+func unsuspendUser(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users unsuspend", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := controllers.ReinstateUser(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+	return message("user %s is active again", userID.Hex())
+}
+
+func banUser(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users ban", flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason shown to moderators")
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := controllers.BanUser(ctx, userID, *reason); err != nil {
+		return nil, nil, err
+	}
+	return message("user %s banned", userID.Hex())
+}
+
+func setUserRole(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users role", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+	switch role := pos[1]; role {
+	case "user":
+		update["$unset"] = bson.M{"role": ""}
+	case models.RoleModerator, models.RoleAdmin:
+		update["$set"].(bson.M)["role"] = role
+	default:
+		return nil, nil, fmt.Errorf("role must be user, %s or %s", models.RoleModerator, models.RoleAdmin)
+	}
+	res, err := database.DB.Collection("users").UpdateByID(ctx, userID, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return message("user %s is now %s", userID.Hex(), pos[1])
+}
+
+// setUserDateOfBirth corrects a date of birth, which users cannot change once set
+func setUserDateOfBirth(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users set-dob", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	dob, err := time.Parse("2006-01-02", pos[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid date of birth: %v", err)
+	}
+	if age := utils.AgeAt(dob, time.Now()); age < config.MinimumAge || age > 120 {
+		return nil, nil, fmt.Errorf("date of birth gives age %d, must be between %d and 120", age, config.MinimumAge)
+	}
+
+	update := bson.M{"$set": bson.M{"date_of_birth": dob, "updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+	res, err := database.DB.Collection("users").UpdateByID(ctx, userID, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	return message("user %s date of birth set to %s", userID.Hex(), pos[1])
+}
+
+func deleteUser(ctx context.Context, args []string) (interface{}, *table, error) {
+	fs := flag.NewFlagSet("users delete", flag.ContinueOnError)
+	pos, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := objectID("user", pos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// photo files go first: if the storage backend fails, the user and the keys of their
+	// photos are still there and the command can be run again
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, nil, err
+	}
+	if err := deletePhotoFiles(ctx, user.Photos); err != nil {
+		return nil, nil, fmt.Errorf("deleting photo files failed, user kept: %v", err)
+	}
+	if err := database.DB.Collection("users").FindOneAndDelete(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, nil, err
+	}
+	// and photos uploaded in the meantime
+	if err := deletePhotoFiles(ctx, user.Photos); err != nil {
+		return nil, nil, fmt.Errorf("user deleted but deleting photo files failed: %v", err)
+	}
+
+	// ratings the user gave are withdrawn, so the people they rated need new scores
+	ratees, err := database.DB.Collection("ratings").Distinct(ctx, "ratee_id", bson.M{"rater_id": userID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("user deleted but reading their ratings failed: %v", err)
+	}
+	// and their connections lose one
+	connected, err := database.DB.Collection("connections").Distinct(ctx, "user_ids", bson.M{"user_ids": userID, "status": models.ConnectionAccepted})
+	if err != nil {
+		return nil, nil, fmt.Errorf("user deleted but reading their connections failed: %v", err)
+	}
+
+	// data that is meaningless without the user; chats stay for the other participants
+	owned := map[string]bson.M{
+		"user_interests":     {"user_id": userID},
+		"availabilities":     {"user_id": userID},
+		"active_proximities": {"user_id": userID},
+		"user_settings":      {"_id": userID},
+		"chat_reads":         {"user_id": userID},
+		"user_warnings":      {"user_id": userID},
+		"ratings":            {"$or": bson.A{bson.M{"rater_id": userID}, bson.M{"ratee_id": userID}}},
+		"user_blocks":        {"$or": bson.A{bson.M{"blocker_id": userID}, bson.M{"blocked_id": userID}}},
+		"connections":        {"user_ids": userID},
+		"profile_views":      {"$or": bson.A{bson.M{"viewer_id": userID}, bson.M{"viewed_id": userID}}},
+		"meeting_requests":   {"$or": bson.A{bson.M{"requester_id": userID}, bson.M{"target_user_id": userID}}},
+	}
+	for collection, filter := range owned {
+		if _, err := database.DB.Collection(collection).DeleteMany(ctx, filter); err != nil {
+			return nil, nil, fmt.Errorf("user deleted but cleaning %s failed: %v", collection, err)
+		}
+	}
+	_, err = database.DB.Collection("users").UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": connected, "$ne": userID}},
+		bson.M{"$inc": bson.M{"connection_count": -1, "version": 1}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("user deleted but updating connection counts failed: %v", err)
+	}
+	if len(ratees) > 0 {
+		if _, err := controllers.RecomputeTrustScores(ctx, bson.M{"_id": bson.M{"$in": ratees}}); err != nil {
+			return nil, nil, fmt.Errorf("user deleted but recomputing trust scores failed: %v", err)
+		}
+	}
+	return message("user %s deleted", userID.Hex())
+}
+
+// deletePhotoFiles removes the images and thumbnails of photos from the storage backend.
+// Deleting a missing file succeeds, so it is safe to repeat.
+func deletePhotoFiles(ctx context.Context, photos []models.Photo) error {
+	for _, photo := range photos {
+		for _, key := range []string{photo.Key, photo.ThumbnailKey} {
+			if err := storage.Files.Delete(ctx, key); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
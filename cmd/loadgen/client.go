@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// apiClient calls the /api/v2 endpoints as one virtual user
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+	rec   *recorder
+}
+
+type apiError struct {
+	status int
+	body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+// do sends a JSON request, records its latency under op and decodes a 2xx response into out
+func (c *apiClient) do(op string, method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, c.base+"/api/v2"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
+		if err == nil && resp.StatusCode >= 300 {
+			err = &apiError{status: resp.StatusCode, body: string(data)}
+		}
+		if err == nil && out != nil {
+			err = json.Unmarshal(data, out)
+		}
+	}
+	c.rec.observe(op, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,179 @@
+// Command loadgen simulates virtual users against a running API instance. Each one
+// logs in, starts a proximity session and random-walks with UpdateProximityLocation
+// while polling GetNearbyUsers; chatters are paired and exchange messages over the
+// chat WebSocket. It reports latency percentiles, error counts and message fan-out delay.
+//
+//	ENABLE_DEV_LOGIN=true go run ./cmd/main.go        # the instance under test
+//	go run ./cmd/seed -users 500 -drop
+//	go run ./cmd/loadgen -users 200 -duration 2m
+//
+// Virtual users sign in through /api/v2/auth/dev/login with seeded accounts, which
+// loadgen reads from the database configured in .env.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type options struct {
+	base      string
+	users     int
+	duration  time.Duration
+	ramp      time.Duration
+	moveEvery time.Duration
+	pollEvery time.Duration
+	step      float64
+	radius    float64
+	chatters  float64
+	msgRate   float64
+	centerLat float64
+	centerLon float64
+	spread    float64
+	seed      int64
+}
+
+// scatter returns a start position normally distributed around the centre
+func (o *options) scatter(rng *rand.Rand) (float64, float64) {
+	const metersPerDegree = 111320.0
+	lat := o.centerLat + rng.NormFloat64()*o.spread/2/metersPerDegree
+	lon := o.centerLon + rng.NormFloat64()*o.spread/2/(metersPerDegree*math.Cos(o.centerLat*math.Pi/180))
+	return lat, lon
+}
+
+func main() {
+	opts := &options{}
+	center := flag.String("center", "12.9716:77.5946", "lat:lon the virtual users move around")
+	flag.StringVar(&opts.base, "base", "http://localhost:3000", "API base URL")
+	flag.IntVar(&opts.users, "users", 50, "number of virtual users")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "test duration after ramp-up")
+	flag.DurationVar(&opts.ramp, "ramp", 10*time.Second, "time over which virtual users start")
+	flag.DurationVar(&opts.moveEvery, "move-every", 2*time.Second, "mean interval between location updates")
+	flag.DurationVar(&opts.pollEvery, "poll-every", 5*time.Second, "mean interval between nearby polls")
+	flag.Float64Var(&opts.step, "step", 15, "meters moved per location update")
+	flag.Float64Var(&opts.radius, "radius", 2000, "proximity radius in meters")
+	flag.Float64Var(&opts.chatters, "chatters", 0.5, "fraction of virtual users that chat")
+	flag.Float64Var(&opts.msgRate, "msg-rate", 0.5, "messages per second per chatter")
+	flag.Float64Var(&opts.spread, "spread", 3000, "meters virtual users start around the centre")
+	flag.Int64Var(&opts.seed, "seed", 1, "random seed")
+	flag.Parse()
+
+	parts := strings.Split(*center, ":")
+	if len(parts) != 2 {
+		log.Fatal("-center must be lat:lon")
+	}
+	var err error
+	if opts.centerLat, err = strconv.ParseFloat(parts[0], 64); err != nil {
+		log.Fatal("invalid -center latitude")
+	}
+	if opts.centerLon, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		log.Fatal("invalid -center longitude")
+	}
+
+	emails := seededEmails(opts.users)
+	if len(emails) < opts.users {
+		log.Printf("only %d seeded users found, running with that many", len(emails))
+		opts.users = len(emails)
+	}
+
+	rec := newRecorder()
+	fanout := &fanoutStats{}
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: opts.users},
+	}
+
+	// chatters come in pairs, so round down to an even count
+	chatterCount := int(float64(opts.users)*opts.chatters) / 2 * 2
+	vus := make([]*virtualUser, opts.users)
+	for i := range vus {
+		vus[i] = &virtualUser{
+			id:       i,
+			email:    emails[i],
+			api:      &apiClient{base: strings.TrimRight(opts.base, "/"), http: httpClient, rec: rec},
+			opts:     opts,
+			rng:      rand.New(rand.NewSource(opts.seed + int64(i))),
+			chatter:  i < chatterCount,
+			ready:    make(chan struct{}),
+			windowID: make(chan string, 1),
+			fanout:   fanout,
+		}
+	}
+	for i := 0; i+1 < chatterCount; i += 2 {
+		vus[i].peer, vus[i+1].peer = vus[i+1], vus[i]
+	}
+
+	fmt.Printf("starting %d virtual users (%d chatters) against %s\n", opts.users, chatterCount, opts.base)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.ramp+opts.duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, vu := range vus {
+		wg.Add(1)
+		go func(delay time.Duration, vu *virtualUser) {
+			defer wg.Done()
+			select {
+			case <-time.After(delay):
+				vu.run(ctx)
+			case <-ctx.Done():
+			}
+		}(time.Duration(int64(opts.ramp)*int64(i)/int64(max(opts.users, 1))), vu)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	fmt.Printf("\nran for %v\n\n", elapsed.Round(time.Second))
+	rec.report(elapsed)
+	sent, received := fanout.sent.Load(), fanout.received.Load()
+	fmt.Printf("\nchat messages: %d sent, %d delivered", sent, received)
+	if sent > 0 {
+		fmt.Printf(" (%.1f%%)", 100*float64(received)/float64(sent))
+	}
+	fmt.Println()
+}
+
+// seededEmails returns up to n emails of users created by cmd/seed
+func seededEmails(n int) []string {
+	config.LoadConfig()
+	database.ConnectMongo()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	filter := bson.M{"email": bson.M{"$regex": `@seed\.fast-af\.dev$`}}
+	opts := mongoOptions.Find().SetProjection(bson.M{"email": 1}).SetSort(bson.M{"_id": 1}).SetLimit(int64(n))
+	cursor, err := database.DB.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var docs []struct {
+		Email string `bson:"email"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		log.Fatal(err)
+	}
+	if len(docs) == 0 {
+		log.Fatal("no seeded users found; run go run ./cmd/seed first")
+	}
+	emails := make([]string, len(docs))
+	for i, d := range docs {
+		emails[i] = d.Email
+	}
+	return emails
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects latencies and errors per operation name
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	errSample map[string]string
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: map[string][]time.Duration{},
+		errors:    map[string]int{},
+		errSample: map[string]string{},
+	}
+}
+
+func (r *recorder) observe(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[op]++
+		if _, ok := r.errSample[op]; !ok {
+			r.errSample[op] = err.Error()
+		}
+		return
+	}
+	r.latencies[op] = append(r.latencies[op], d)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+func (r *recorder) report(elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ops := map[string]bool{}
+	for op := range r.latencies {
+		ops[op] = true
+	}
+	for op := range r.errors {
+		ops[op] = true
+	}
+	var names []string
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "OPERATION\tOK\tERRORS\tRATE/s\tP50\tP90\tP99\tMAX\t")
+	for _, op := range names {
+		lat := r.latencies[op]
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		var max time.Duration
+		if len(lat) > 0 {
+			max = lat[len(lat)-1]
+		}
+		rate := float64(len(lat)+r.errors[op]) / elapsed.Seconds()
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%v\t%v\t%v\t%v\t\n", op, len(lat), r.errors[op], rate,
+			round(percentile(lat, 0.50)), round(percentile(lat, 0.90)), round(percentile(lat, 0.99)), round(max))
+	}
+	w.Flush()
+
+	if len(r.errSample) > 0 {
+		fmt.Println("\nfirst error per operation:")
+		for _, op := range names {
+			if sample, ok := r.errSample[op]; ok {
+				fmt.Printf("  %s: %s\n", op, sample)
+			}
+		}
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fasthttp/websocket"
+)
+
+// virtualUser moves around a city centre and, if it is a chatter, talks to its peer
+type virtualUser struct {
+	id     int
+	email  string
+	api    *apiClient
+	opts   *options
+	rng    *rand.Rand
+	userID string // set before ready is closed
+	ready  chan struct{}
+	lat    float64
+	lon    float64
+
+	// chat pairing: the even member of a pair opens the window and hands it to the odd one
+	chatter  bool
+	peer     *virtualUser
+	windowID chan string
+	fanout   *fanoutStats
+}
+
+// chatEnvelope is the frame chatters exchange; SentAt measures fan-out delay
+type chatEnvelope struct {
+	From   int   `json:"from"`
+	SentAt int64 `json:"sentAt"` // unix nanoseconds
+	Seq    int   `json:"seq"`
+}
+
+type fanoutStats struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func (vu *virtualUser) run(ctx context.Context) {
+	if err := vu.login(); err != nil {
+		if vu.chatter && vu.id%2 == 0 {
+			close(vu.windowID)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	if vu.startProximity() == nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vu.moveAndPoll(ctx)
+		}()
+	}
+	if vu.chatter {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vu.chat(ctx)
+		}()
+	}
+	wg.Wait()
+
+	// leave the instance as we found it
+	vu.api.do("proximity.stop", "DELETE", "/users/me/proximity", nil, nil)
+	vu.api.do("availability.unset", "DELETE", "/users/me/availability/now", nil, nil)
+}
+
+func (vu *virtualUser) login() error {
+	defer close(vu.ready)
+	var resp struct {
+		Token string `json:"token"`
+		User  struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := vu.api.do("auth.login", "POST", "/auth/dev/login", map[string]string{"email": vu.email}, &resp); err != nil {
+		return err
+	}
+	vu.api.token = resp.Token
+	vu.userID = resp.User.ID
+	return nil
+}
+
+func (vu *virtualUser) startProximity() error {
+	// seeded users may already have a session
+	vu.api.do("proximity.stop", "DELETE", "/users/me/proximity", nil, nil)
+
+	var avail struct {
+		AvailabilityID string `json:"availabilityId"`
+	}
+	if err := vu.api.do("availability.set", "PUT", "/users/me/availability/now", nil, &avail); err != nil {
+		return err
+	}
+	vu.lat, vu.lon = vu.opts.scatter(vu.rng)
+	body := map[string]interface{}{
+		"latitude":       vu.lat,
+		"longitude":      vu.lon,
+		"radius":         vu.opts.radius,
+		"availabilityId": avail.AvailabilityID,
+	}
+	return vu.api.do("proximity.start", "POST", "/users/me/proximity", body, nil)
+}
+
+// moveAndPoll walks randomly, sending UpdateProximityLocation and polling GetNearbyUsers
+func (vu *virtualUser) moveAndPoll(ctx context.Context) {
+	move := time.NewTicker(jitter(vu.rng, vu.opts.moveEvery))
+	poll := time.NewTicker(jitter(vu.rng, vu.opts.pollEvery))
+	defer move.Stop()
+	defer poll.Stop()
+
+	heading := vu.rng.Float64() * 2 * math.Pi
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-move.C:
+			heading += vu.rng.NormFloat64() * 0.5
+			const metersPerDegree = 111320.0
+			vu.lat += vu.opts.step * math.Cos(heading) / metersPerDegree
+			vu.lon += vu.opts.step * math.Sin(heading) / (metersPerDegree * math.Cos(vu.lat*math.Pi/180))
+			vu.api.do("proximity.update", "PATCH", "/users/me/proximity", map[string]float64{"latitude": vu.lat, "longitude": vu.lon}, nil)
+		case <-poll.C:
+			vu.api.do("proximity.nearby", "GET", "/users/me/proximity/nearby", nil, nil)
+		}
+	}
+}
+
+func (vu *virtualUser) chat(ctx context.Context) {
+	windowID, ok := vu.chatWindow(ctx)
+	if !ok {
+		return
+	}
+
+	wsURL := strings.Replace(vu.api.base, "http", "ws", 1) + "/api/v2/chat/ws?" + url.Values{
+		"chatWindowId": {windowID},
+		"access_token": {vu.api.token},
+	}.Encode()
+	start := time.Now()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	vu.api.rec.observe("chat.connect", time.Since(start), err)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// reader: every frame from the peer yields one fan-out delay sample
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					vu.api.rec.observe("chat.receive", 0, err)
+				}
+				return
+			}
+			var env chatEnvelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				vu.api.rec.observe("chat.receive", 0, errors.New("unexpected frame: "+string(data)))
+				continue
+			}
+			vu.fanout.received.Add(1)
+			vu.api.rec.observe("chat.fanout", time.Since(time.Unix(0, env.SentAt)), nil)
+		}
+	}()
+
+	if vu.opts.msgRate <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(jitter(vu.rng, time.Duration(float64(time.Second)/vu.opts.msgRate)))
+	defer ticker.Stop()
+	for seq := 0; ; seq++ {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return
+		case <-ticker.C:
+			frame, _ := json.Marshal(chatEnvelope{From: vu.id, SentAt: time.Now().UnixNano(), Seq: seq})
+			start := time.Now()
+			err := conn.WriteMessage(websocket.TextMessage, frame)
+			vu.api.rec.observe("chat.send", time.Since(start), err)
+			if err != nil {
+				return
+			}
+			vu.fanout.sent.Add(1)
+		}
+	}
+}
+
+// chatWindow opens (even member) or waits for (odd member) the pair's chat window
+func (vu *virtualUser) chatWindow(ctx context.Context) (string, bool) {
+	if vu.id%2 == 1 {
+		select {
+		case id, ok := <-vu.peer.windowID:
+			return id, ok
+		case <-ctx.Done():
+			return "", false
+		}
+	}
+	defer close(vu.windowID)
+
+	// the peer logs in concurrently; wait for its user id
+	select {
+	case <-vu.peer.ready:
+	case <-ctx.Done():
+		return "", false
+	}
+	if vu.peer.userID == "" {
+		return "", false
+	}
+	var window struct {
+		ID string `json:"id"`
+	}
+	if err := vu.api.do("chat.window", "POST", "/chat/windows", map[string]interface{}{"participantIds": []string{vu.peer.userID}}, &window); err != nil {
+		return "", false
+	}
+	vu.windowID <- window.ID
+	return window.ID, true
+}
+
+// jitter spreads periodic work of many virtual users so they do not fire in lockstep
+func jitter(rng *rand.Rand, d time.Duration) time.Duration {
+	if d <= 0 {
+		return time.Millisecond
+	}
+	return d/2 + time.Duration(rng.Int63n(int64(d)))
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"fast-af/config"
+	"fast-af/controllers"
 	"fast-af/database"
 	"fast-af/routes"
+	"fast-af/storage"
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,9 +19,26 @@ func main() {
 
 	// connect to mongo
 	database.ConnectMongo()
+	database.EnsureIndexes()
+	database.Migrate()
+
+	// setup the photo storage backend
+	storage.Setup()
+
+	// reactivate users whose suspension has ended
+	go controllers.LiftExpiredSuspensionsEvery(time.Minute)
+
+	// keep trust scores current as ratings age and new signals come in
+	go controllers.RecomputeTrustScoresEvery(config.TrustRecomputeInterval)
+
+	// tell chat partners when connected users go away
+	go controllers.SweepPresenceEvery(15 * time.Second)
 
 	// create a new fiber instance
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		// leave room for multipart overhead around photo uploads
+		BodyLimit: config.MaxPhotoUploadBytes + 1<<20,
+	})
 
 	// setup the routes
 	routes.SetupRoutes(app)
@@ -0,0 +1,139 @@
+package main
+
+// Static vocabularies the generator draws from. Order matters: it is part of the
+// deterministic output for a given seed.
+
+var firstNames = []string{
+	"Aarav", "Maya", "Liam", "Priya", "Noah", "Sara", "Kabir", "Elena", "Omar", "Zoe",
+	"Arjun", "Lena", "Mateo", "Isha", "Felix", "Nora", "Ravi", "Chloe", "Jonas", "Ananya",
+	"Leo", "Amara", "Dev", "Ines", "Hugo", "Tara", "Samir", "Julia", "Kenji", "Aisha",
+}
+
+var lastNames = []string{
+	"Sharma", "Okafor", "Fischer", "Costa", "Nguyen", "Iyer", "Haddad", "Novak", "Silva", "Kim",
+	"Mehta", "Larsen", "Rossi", "Kapoor", "Dubois", "Tanaka", "Reyes", "Bose", "Schmidt", "Ali",
+}
+
+var genders = []string{"female", "male", "non-binary", "female", "male"} // weighted by repetition
+
+var neighbourhoods = []string{"Central", "North", "South", "East", "West", "Old Town", "Riverside", "University"}
+
+var bioTemplates = []string{
+	"New in town and looking for people into %s.",
+	"Weekend %s enthusiast, weekday coffee addict.",
+	"Always up for %s or a long walk.",
+	"Ask me about %s.",
+	"Trying to get better at %s, happy to learn together.",
+}
+
+var messageTemplates = []string{
+	"Hey! I saw you're into %s too",
+	"Are you around this weekend?",
+	"That sounds great",
+	"Where do you usually go for %s?",
+	"I'm free after 6",
+	"Let's do it!",
+	"Haha, same here",
+	"Do you know a good place near %s?",
+	"Running 10 minutes late, sorry!",
+	"Nice meeting you today",
+}
+
+var meetingMessages = []string{
+	"Want to grab a coffee?",
+	"Join me for %s?",
+	"I'll be around, want to meet?",
+	"",
+}
+
+var positiveReviews = []string{
+	"Great company, we talked for hours.",
+	"Showed up on time and was exactly like their profile.",
+	"Really friendly, would meet again.",
+	"Fun afternoon, thanks for the recommendations!",
+}
+
+var criticalReviews = []string{
+	"Arrived quite late.",
+	"Nice enough, but we didn't have much in common.",
+	"Photos were a bit out of date.",
+}
+
+// seedCategory is a node of the interest category tree; parent is the parent's slug
+type seedCategory struct {
+	slug, name, parent string
+}
+
+// categoryTree lists parents before their children and siblings in display order
+var categoryTree = []seedCategory{
+	{"sports", "Sports", ""},
+	{"climbing", "Climbing", "sports"},
+	{"ball-sports", "Ball Sports", "sports"},
+	{"fitness", "Fitness", ""},
+	{"mind-body", "Mind & Body", "fitness"},
+	{"outdoors", "Outdoors", ""},
+	{"nature", "Nature", "outdoors"},
+	{"food-drink", "Food & Drink", ""},
+	{"cooking-baking", "Cooking & Baking", "food-drink"},
+	{"arts", "Arts", ""},
+	{"crafts", "Crafts", "arts"},
+	{"music", "Music", ""},
+	{"games", "Games", ""},
+	{"tabletop", "Tabletop", "games"},
+	{"culture", "Culture", ""},
+	{"tech", "Tech", ""},
+	{"lifestyle", "Lifestyle", ""},
+	{"community", "Community", ""},
+}
+
+type seedInterest struct {
+	name, category, description string // category is a slug from categoryTree
+}
+
+// interestCatalog is ordered roughly by expected popularity; user interests are drawn
+// from it with a Zipf distribution so the first entries are by far the most common.
+var interestCatalog = []seedInterest{
+	{"coffee", "food-drink", "Trying new cafés"},
+	{"hiking", "outdoors", "Day hikes and trails"},
+	{"running", "sports", "Casual and competitive running"},
+	{"board games", "tabletop", "Strategy and party games"},
+	{"live music", "music", "Gigs and concerts"},
+	{"cooking", "cooking-baking", "Home cooking and swaps"},
+	{"photography", "arts", "Street and landscape photography"},
+	{"yoga", "mind-body", "Yoga classes and sessions"},
+	{"climbing", "climbing", "Indoor and outdoor climbing"},
+	{"cycling", "sports", "Road and city rides"},
+	{"reading", "culture", "Book clubs and swaps"},
+	{"football", "ball-sports", "Pickup games and watching matches"},
+	{"movies", "culture", "Cinema nights"},
+	{"video games", "games", "Co-op and competitive gaming"},
+	{"gym", "fitness", "Weight training"},
+	{"travel", "lifestyle", "Trip planning and stories"},
+	{"dancing", "music", "Salsa, swing and more"},
+	{"painting", "arts", "Watercolour and acrylics"},
+	{"tennis", "ball-sports", "Singles and doubles"},
+	{"language exchange", "culture", "Practice a new language"},
+	{"swimming", "fitness", "Pool and open water"},
+	{"chess", "tabletop", "Casual and rated games"},
+	{"baking", "cooking-baking", "Bread, cakes and pastry"},
+	{"startups", "tech", "Founders and side projects"},
+	{"programming", "tech", "Hack nights"},
+	{"volunteering", "community", "Local causes"},
+	{"bouldering", "climbing", "Bouldering gyms"},
+	{"wine tasting", "food-drink", "Tastings and vineyards"},
+	{"meditation", "mind-body", "Group sits"},
+	{"theatre", "culture", "Plays and improv"},
+	{"gardening", "nature", "Community gardens"},
+	{"skateboarding", "sports", "Parks and street"},
+	{"poetry", "arts", "Open mics and readings"},
+	{"astronomy", "outdoors", "Stargazing"},
+	{"knitting", "crafts", "Knit and natter"},
+	{"birdwatching", "nature", "Early morning walks"},
+}
+
+type city struct {
+	name     string
+	lat, lon float64
+}
+
+var defaultCities = "Bengaluru:12.9716:77.5946,Berlin:52.5200:13.4050,Lisbon:38.7223:-9.1393"
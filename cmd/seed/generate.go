@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// generator produces a complete, internally consistent data set. Every random
+// choice goes through rng so a seed and a reference time fully determine the output.
+type generator struct {
+	rng    *rand.Rand
+	now    time.Time
+	cities []city
+	spread float64 // meters around a city centre
+
+	users           []models.User
+	userCity        []int
+	categories      []models.InterestCategory
+	interests       []models.Interest
+	userInterests   []models.UserInterest
+	interestsByUser map[primitive.ObjectID][]int
+	availabilities  []models.Availablility
+	futureByUser    map[primitive.ObjectID][]models.Availablility
+	proximities     []models.ActiveProximity
+	chatWindows     []models.ChatWindow
+	chats           []models.Chat
+	meetingRequests []models.MeetingRequest
+	ratings         []models.Rating
+}
+
+func newGenerator(seed int64, now time.Time, cities []city, spread float64) *generator {
+	return &generator{
+		rng:             rand.New(rand.NewSource(seed)),
+		now:             now,
+		cities:          cities,
+		spread:          spread,
+		interestsByUser: map[primitive.ObjectID][]int{},
+		futureByUser:    map[primitive.ObjectID][]models.Availablility{},
+	}
+}
+
+// objectID builds an ObjectID whose timestamp is t and whose remaining bytes come from rng
+func (g *generator) objectID(t time.Time) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[0:4], uint32(t.Unix()))
+	g.rng.Read(id[4:])
+	return id
+}
+
+func (g *generator) pick(options []string) string {
+	return options[g.rng.Intn(len(options))]
+}
+
+func (g *generator) generate(userCount int, windows int) {
+	g.generateInterests()
+	g.generateUsers(userCount)
+	g.generateUserInterests()
+	g.generateRatings()
+	g.generateAvailabilities()
+	g.generateChats(windows)
+	g.generateMeetingRequests()
+}
+
+func (g *generator) generateInterests() {
+	bySlug := map[string]*models.InterestCategory{}
+	siblings := map[string]int{}
+	for _, sc := range categoryTree {
+		category := models.InterestCategory{
+			ID:    g.objectID(g.now.AddDate(-1, 0, 0)),
+			Name:  sc.name,
+			Slug:  sc.slug,
+			Path:  []primitive.ObjectID{},
+			Order: siblings[sc.parent],
+		}
+		siblings[sc.parent]++
+		if parent := bySlug[sc.parent]; parent != nil {
+			category.ParentID = &parent.ID
+			category.Path = append(append(category.Path, parent.Path...), parent.ID)
+		}
+		g.categories = append(g.categories, category)
+		bySlug[sc.slug] = &category
+	}
+	for _, si := range interestCatalog {
+		category := bySlug[si.category]
+		g.interests = append(g.interests, models.Interest{
+			ID:          g.objectID(g.now.AddDate(-1, 0, 0)),
+			Name:        si.name,
+			CategoryID:  &category.ID,
+			Category:    category.Name,
+			Description: si.description,
+		})
+	}
+}
+
+func (g *generator) generateUsers(n int) {
+	for i := 0; i < n; i++ {
+		first, last := g.pick(firstNames), g.pick(lastNames)
+		cityIdx := g.rng.Intn(len(g.cities))
+		createdAt := g.now.Add(-time.Duration(g.rng.Int63n(int64(365 * 24 * time.Hour))))
+
+		// ages cluster in the mid twenties with a long tail up to 65
+		age := 18 + int(math.Min(47, math.Abs(g.rng.NormFloat64()*9+7)))
+		birthday := time.Date(g.now.Year(), g.now.Month(), g.now.Day(), 0, 0, 0, 0, time.UTC)
+		dob := birthday.AddDate(-age, 0, -g.rng.Intn(365))
+
+		// about a third only want to meet people close to their own age
+		var agePreference *models.AgeRange
+		if g.rng.Float64() < 0.35 {
+			agePreference = &models.AgeRange{Min: max(18, age-5-g.rng.Intn(5)), Max: age + 5 + g.rng.Intn(10)}
+		}
+
+		user := models.User{
+			ID:            g.objectID(createdAt),
+			Email:         fmt.Sprintf("%s.%s.%d@seed.fast-af.dev", strings.ToLower(first), strings.ToLower(last), i),
+			Name:          first + " " + last,
+			DateOfBirth:   &dob,
+			AgePreference: agePreference,
+			Gender:        g.pick(genders),
+			Locality:      g.pick(neighbourhoods) + ", " + g.cities[cityIdx].name,
+			Verified:      g.rng.Float64() < 0.6,
+			EmailVerified: g.rng.Float64() < 0.9,
+			Status:        models.UserStatusActive,
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
+		}
+		g.users = append(g.users, user)
+		g.userCity = append(g.userCity, cityIdx)
+	}
+}
+
+// generateUserInterests gives every user 2-8 interests drawn from a power-law over the catalog
+func (g *generator) generateUserInterests() {
+	zipf := rand.NewZipf(g.rng, 1.3, 2, uint64(len(g.interests)-1))
+	for i := range g.users {
+		user := &g.users[i]
+		want := 2 + g.rng.Intn(7)
+		seen := map[int]bool{}
+		for attempts := 0; len(seen) < want && attempts < want*10; attempts++ {
+			idx := int(zipf.Uint64())
+			if seen[idx] {
+				continue
+			}
+			seen[idx] = true
+			g.userInterests = append(g.userInterests, models.UserInterest{
+				ID:         g.objectID(user.CreatedAt),
+				UserID:     user.ID,
+				InterestID: g.interests[idx].ID,
+			})
+			g.interestsByUser[user.ID] = append(g.interestsByUser[user.ID], idx)
+		}
+		user.Bio = fmt.Sprintf(g.pick(bioTemplates), g.favouriteInterest(user.ID))
+	}
+}
+
+// generateRatings has about 70% of users rated by a handful of people from their city,
+// with scores spread around a per-user mean. trust_score is computed once they are stored.
+func (g *generator) generateRatings() {
+	if len(g.users) < 2 {
+		return
+	}
+	for i := range g.users {
+		user := &g.users[i]
+		if g.rng.Float64() >= 0.7 {
+			continue
+		}
+		mean := 4.2 + g.rng.NormFloat64()*0.6
+		raters := map[int]bool{}
+		for n := 1 + int(g.rng.ExpFloat64()*8); n > 0; n-- {
+			r := g.sameCityPeer(i)
+			if raters[r] {
+				continue
+			}
+			raters[r] = true
+			since := user.CreatedAt
+			if g.users[r].CreatedAt.After(since) {
+				since = g.users[r].CreatedAt
+			}
+			at := since.Add(time.Duration(g.rng.Int63n(int64(g.now.Sub(since)) + 1)))
+			rating := models.Rating{
+				ID:        g.objectID(at),
+				RaterID:   g.users[r].ID,
+				RateeID:   user.ID,
+				Score:     math.Round(math.Min(5, math.Max(1, mean+g.rng.NormFloat64()*0.7))),
+				CreatedAt: at,
+				UpdatedAt: at,
+			}
+			if g.rng.Float64() < 0.4 {
+				if rating.Score >= 4 {
+					rating.Review = g.pick(positiveReviews)
+				} else {
+					rating.Review = g.pick(criticalReviews)
+				}
+			}
+			g.ratings = append(g.ratings, rating)
+		}
+		user.UsersRated = len(raters)
+	}
+}
+
+func (g *generator) favouriteInterest(userID primitive.ObjectID) string {
+	idxs := g.interestsByUser[userID]
+	if len(idxs) == 0 {
+		return "meeting new people"
+	}
+	return g.interests[idxs[0]].Name
+}
+
+// generateAvailabilities creates "available now" slots with proximity sessions for about
+// a third of the users, and up to three future slots over the next two weeks for most.
+func (g *generator) generateAvailabilities() {
+	for i, user := range g.users {
+		if g.rng.Float64() < 0.35 {
+			start := g.now.Add(-time.Duration(g.rng.Intn(90)) * time.Minute)
+			avail := models.Availablility{
+				ID:          g.objectID(start),
+				UserID:      user.ID,
+				Date:        start.Format("2006-01-02"),
+				StartTime:   start.Format("15:04"),
+				EndTime:     "",
+				IsAvailable: true,
+				Location:    user.Locality,
+			}
+			g.availabilities = append(g.availabilities, avail)
+			g.proximities = append(g.proximities, g.proximityFor(user, g.cities[g.userCity[i]], avail, start))
+		}
+
+		for n := g.rng.Intn(4); n > 0; n-- {
+			day := g.now.AddDate(0, 0, 1+g.rng.Intn(14))
+			startHour := 8 + g.rng.Intn(12)
+			start := time.Date(day.Year(), day.Month(), day.Day(), startHour, 30*g.rng.Intn(2), 0, 0, day.Location())
+			end := start.Add(time.Duration(1+g.rng.Intn(3)) * time.Hour)
+			avail := models.Availablility{
+				ID:          g.objectID(g.now),
+				UserID:      user.ID,
+				Date:        start.Format("2006-01-02"),
+				StartTime:   start.Format("15:04"),
+				EndTime:     end.Format("15:04"),
+				IsAvailable: true,
+				Location:    g.pick(neighbourhoods) + ", " + g.cities[g.userCity[i]].name,
+			}
+			g.availabilities = append(g.availabilities, avail)
+			g.futureByUser[user.ID] = append(g.futureByUser[user.ID], avail)
+		}
+	}
+}
+
+// proximityFor scatters a session around the city centre with a normal distribution
+func (g *generator) proximityFor(user models.User, c city, avail models.Availablility, start time.Time) models.ActiveProximity {
+	const metersPerDegree = 111320.0
+	dLat := g.rng.NormFloat64() * g.spread / 2 / metersPerDegree
+	dLon := g.rng.NormFloat64() * g.spread / 2 / (metersPerDegree * math.Cos(c.lat*math.Pi/180))
+	return models.ActiveProximity{
+		ID:             g.objectID(start),
+		UserID:         user.ID,
+		AvailabilityID: avail.ID,
+		Latitude:       c.lat + dLat,
+		Longitude:      c.lon + dLon,
+		Radius:         float64(500 * (1 + g.rng.Intn(10))),
+		CreatedAt:      start,
+		ExpiresAt:      g.now.Add(time.Duration(30+g.rng.Intn(150)) * time.Minute),
+	}
+}
+
+// generateChats opens 1-1 windows between users of the same city and fills them with messages
+func (g *generator) generateChats(windows int) {
+	if len(g.users) < 2 {
+		return
+	}
+	for w := 0; w < windows; w++ {
+		a := g.rng.Intn(len(g.users))
+		b := g.sameCityPeer(a)
+		createdAt := g.now.Add(-time.Duration(g.rng.Int63n(int64(30 * 24 * time.Hour))))
+		window := models.ChatWindow{
+			ID:             g.objectID(createdAt),
+			ParticipantIDs: []primitive.ObjectID{g.users[a].ID, g.users[b].ID},
+			CreatedAt:      createdAt,
+			UpdatedAt:      createdAt,
+		}
+
+		at := createdAt
+		for n := 3 + g.rng.Intn(18); n > 0; n-- {
+			at = at.Add(time.Duration(1+g.rng.Intn(240)) * time.Minute)
+			if at.After(g.now) {
+				break
+			}
+			sender := window.ParticipantIDs[g.rng.Intn(2)]
+			msg := g.pick(messageTemplates)
+			if strings.Contains(msg, "%s") {
+				msg = fmt.Sprintf(msg, g.favouriteInterest(sender))
+			}
+			g.chats = append(g.chats, models.Chat{
+				ID:           g.objectID(at),
+				ChatWindowID: window.ID,
+				Msg:          msg,
+				CreatedBy:    sender,
+				CreatedAt:    at,
+			})
+			window.UpdatedAt = at
+		}
+		g.chatWindows = append(g.chatWindows, window)
+	}
+}
+
+// sameCityPeer returns another user, preferring one from the same city as user a
+func (g *generator) sameCityPeer(a int) int {
+	for attempts := 0; attempts < 20; attempts++ {
+		b := g.rng.Intn(len(g.users))
+		if b != a && g.userCity[b] == g.userCity[a] {
+			return b
+		}
+	}
+	return (a + 1) % len(g.users)
+}
+
+// generateMeetingRequests sends requests against other users' future slots,
+// cycling through every status so each one is represented.
+func (g *generator) generateMeetingRequests() {
+	statuses := []string{"pending", "accepted", "rejected", "deleted"}
+	n := 0
+	for i, target := range g.users {
+		for _, avail := range g.futureByUser[target.ID] {
+			if g.rng.Float64() < 0.5 {
+				continue
+			}
+			requester := g.users[g.sameCityPeer(i)]
+			createdAt := g.now.Add(-time.Duration(g.rng.Intn(72)) * time.Hour)
+			msg := g.pick(meetingMessages)
+			if strings.Contains(msg, "%s") {
+				msg = fmt.Sprintf(msg, g.favouriteInterest(target.ID))
+			}
+			status := statuses[n%len(statuses)]
+			var version int64
+			if status != "pending" {
+				version = 1
+			}
+			g.meetingRequests = append(g.meetingRequests, models.MeetingRequest{
+				ID:             g.objectID(createdAt),
+				RequesterID:    requester.ID,
+				TargetUserID:   target.ID,
+				AvailabilityID: avail.ID,
+				Message:        msg,
+				Status:         status,
+				Version:        version,
+				CreatedAt:      createdAt,
+				UpdatedAt:      createdAt.Add(time.Duration(version) * time.Hour),
+			})
+			n++
+		}
+	}
+}
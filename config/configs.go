@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
@@ -14,8 +16,72 @@ var DefaultDBContextTimeout int
 var GoogleClientID string
 var GoogleClientSecret string
 
+// AuthTokenSecret signs the bearer tokens handed out at login
+var AuthTokenSecret string
+var AuthTokenTTL time.Duration
+
+// APIV1DeprecatedAt and APIV1Sunset are advertised on every /api/v1 response
+var APIV1DeprecatedAt time.Time
+var APIV1Sunset time.Time
+
+// EnableDevLogin exposes POST /api/v2/auth/dev/login, which signs in any existing user by
+// email without OAuth. Only for local testing and load generation: even when enabled, the
+// route only answers requests made directly from the server's own machine.
+var EnableDevLogin bool
+
+// IdempotencyKeyTTL is how long stored Idempotency-Key responses are replayable
+var IdempotencyKeyTTL time.Duration
+
+// RatingWindow is how long after a meeting ends its participants can rate each other
+var RatingWindow time.Duration
+
+// Trust scores are a Bayesian average of ratings that starts every user at TrustPriorMean
+// as if they had TrustPriorWeight ratings of that score (TRUST_PRIOR_MEAN, default 3.5;
+// TRUST_PRIOR_WEIGHT, default 5). A rating counts half as much every TrustRatingHalfLife,
+// and scores are recomputed every TrustRecomputeInterval.
+var TrustPriorMean float64
+var TrustPriorWeight float64
+var TrustRatingHalfLife time.Duration
+var TrustRecomputeInterval time.Duration
+
+// Chat sockets keep users online; a connected user with no messages or heartbeats for
+// PresenceAwayAfter shows as away (PRESENCE_AWAY_AFTER, default 5m)
+var PresenceAwayAfter time.Duration
+
+// ProfileViewRetention is how long profile views are kept (PROFILE_VIEW_RETENTION, default 90 days)
+var ProfileViewRetention time.Duration
+
+// MinimumAge is the youngest age allowed to sign up and log in (MINIMUM_AGE, default 18)
+var MinimumAge int
+
+// Photo uploads: STORAGE_BACKEND is "local" (files under LOCAL_STORAGE_DIR) or "s3"
+var StorageBackend string
+var LocalStorageDir string
+var S3Endpoint string
+var S3Region string
+var S3Bucket string
+var S3AccessKey string
+var S3SecretKey string
+var S3PathStyle bool
+var MaxPhotoUploadBytes int
+
+// MaxProfilePhotos caps the size of a user's photo gallery
+var MaxProfilePhotos int
+
+// PhotoAutoApprove publishes new photos immediately instead of holding them for moderation
+var PhotoAutoApprove bool
+
+// PublicBaseURL prefixes links the API hands out, such as signed photo URLs
+var PublicBaseURL string
+
+// MediaURLTTL is the minimum lifetime of the signed URLs photos are served through
+var MediaURLTTL time.Duration
+
 var GoogleOauthConfig oauth2.Config
 
+// GoogleRedirectURLV2 is sent instead of GoogleOauthConfig.RedirectURL for /api/v2 logins
+var GoogleRedirectURLV2 string
+
 func LoadConfig() {
 
 	// load .env file
@@ -29,6 +95,61 @@ func LoadConfig() {
 	MongoURI = os.Getenv("MONGO_URI")
 	DefaultDBContextTimeout = 10
 
+	AuthTokenSecret = os.Getenv("AUTH_TOKEN_SECRET")
+	if AuthTokenSecret == "" {
+		log.Fatal("AUTH_TOKEN_SECRET must be set")
+	}
+	AuthTokenTTL = durationFromEnv("AUTH_TOKEN_TTL", 30*24*time.Hour)
+
+	EnableDevLogin = os.Getenv("ENABLE_DEV_LOGIN") == "true"
+	IdempotencyKeyTTL = durationFromEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	RatingWindow = durationFromEnv("RATING_WINDOW", 14*24*time.Hour)
+	TrustPriorMean = floatFromEnv("TRUST_PRIOR_MEAN", 3.5)
+	TrustPriorWeight = floatFromEnv("TRUST_PRIOR_WEIGHT", 5)
+	TrustRatingHalfLife = durationFromEnv("TRUST_RATING_HALF_LIFE", 180*24*time.Hour)
+	TrustRecomputeInterval = durationFromEnv("TRUST_RECOMPUTE_INTERVAL", time.Hour)
+	if TrustPriorMean < 0 || TrustPriorMean > 5 || TrustPriorWeight < 0 || TrustRatingHalfLife <= 0 || TrustRecomputeInterval <= 0 {
+		log.Fatal("TRUST_PRIOR_MEAN must be between 0 and 5, TRUST_PRIOR_WEIGHT at least 0 and TRUST_RATING_HALF_LIFE and TRUST_RECOMPUTE_INTERVAL positive")
+	}
+	PresenceAwayAfter = durationFromEnv("PRESENCE_AWAY_AFTER", 5*time.Minute)
+	if PresenceAwayAfter <= 0 {
+		log.Fatal("PRESENCE_AWAY_AFTER must be positive")
+	}
+	ProfileViewRetention = durationFromEnv("PROFILE_VIEW_RETENTION", 90*24*time.Hour)
+	if ProfileViewRetention < 24*time.Hour {
+		log.Fatal("PROFILE_VIEW_RETENTION must be at least 24h")
+	}
+	MinimumAge = 18
+	if v, err := strconv.Atoi(os.Getenv("MINIMUM_AGE")); err == nil && v > 0 {
+		MinimumAge = v
+	}
+
+	StorageBackend = os.Getenv("STORAGE_BACKEND")
+	if StorageBackend == "" {
+		StorageBackend = "local"
+	}
+	LocalStorageDir = os.Getenv("LOCAL_STORAGE_DIR")
+	if LocalStorageDir == "" {
+		LocalStorageDir = "uploads"
+	}
+	S3Endpoint = os.Getenv("S3_ENDPOINT")
+	S3Region = os.Getenv("S3_REGION")
+	S3Bucket = os.Getenv("S3_BUCKET")
+	S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	S3SecretKey = os.Getenv("S3_SECRET_KEY")
+	S3PathStyle = os.Getenv("S3_PATH_STYLE") == "true"
+	MaxPhotoUploadBytes = 8 << 20
+	MaxProfilePhotos = 6
+	PhotoAutoApprove = os.Getenv("PHOTO_AUTO_APPROVE") == "true"
+	PublicBaseURL = os.Getenv("PUBLIC_BASE_URL")
+	if PublicBaseURL == "" {
+		PublicBaseURL = "http://localhost:3000"
+	}
+	MediaURLTTL = durationFromEnv("MEDIA_URL_TTL", time.Hour)
+
+	APIV1DeprecatedAt = dateFromEnv("API_V1_DEPRECATED_AT", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	APIV1Sunset = dateFromEnv("API_V1_SUNSET", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+
 	GoogleOauthConfig = oauth2.Config{
 		RedirectURL:  "http://localhost:3000/api/v1/auth/google/callback",
 		ClientID:     GoogleClientID,
@@ -36,4 +157,44 @@ func LoadConfig() {
 		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
 		Endpoint:     google.Endpoint,
 	}
+	GoogleRedirectURLV2 = "http://localhost:3000/api/v2/auth/google/callback"
+}
+
+// durationFromEnv parses a Go duration (e.g. "720h") or falls back to def
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return d
+}
+
+// floatFromEnv parses a number or falls back to def
+func floatFromEnv(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return f
+}
+
+// dateFromEnv parses a YYYY-MM-DD date (UTC) or falls back to def
+func dateFromEnv(key string, def time.Time) time.Time {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return t
 }
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// restrictedAccountsFilter matches banned users and users whose suspension is still running
+func restrictedAccountsFilter(now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"status": models.UserStatusBanned},
+		bson.M{"status": models.UserStatusSuspended, "suspended_until": bson.M{"$not": bson.M{"$lte": now}}},
+	}}
+}
+
+// restrictedAccounts returns which of candidates (nil = everyone) are suspended or banned
+func restrictedAccounts(ctx context.Context, candidates []primitive.ObjectID, now time.Time) ([]primitive.ObjectID, error) {
+	filter := restrictedAccountsFilter(now)
+	if candidates != nil {
+		filter["_id"] = bson.M{"$in": candidates}
+	}
+	ids, err := database.DB.Collection("users").Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	restricted := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			restricted = append(restricted, oid)
+		}
+	}
+	return restricted, nil
+}
+
+// SuspendUser suspends a user until the given time. It never shortens a ban.
+func SuspendUser(ctx context.Context, userID primitive.ObjectID, until time.Time, reason string) error {
+	return restrictAccount(ctx, userID, bson.M{"_id": userID, "status": bson.M{"$ne": models.UserStatusBanned}}, bson.M{
+		"$set": bson.M{
+			"status":            models.UserStatusSuspended,
+			"suspended_until":   until,
+			"suspension_reason": reason,
+			"updated_at":        time.Now(),
+		},
+		"$inc": bson.M{"version": 1},
+	})
+}
+
+// BanUser bans a user indefinitely
+func BanUser(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	return restrictAccount(ctx, userID, bson.M{"_id": userID}, bson.M{
+		"$set":   bson.M{"status": models.UserStatusBanned, "suspension_reason": reason, "updated_at": time.Now()},
+		"$unset": bson.M{"suspended_until": ""},
+		"$inc":   bson.M{"version": 1},
+	})
+}
+
+// restrictAccount applies a suspension or ban and takes the user offline: their proximity
+// sessions end and their chat sockets are closed. Sockets held by another server process
+// close on their next message, once that process's status cache expires.
+func restrictAccount(ctx context.Context, userID primitive.ObjectID, filter bson.M, update bson.M) error {
+	res, err := database.DB.Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if exists, err := database.DB.Collection("users").CountDocuments(ctx, bson.M{"_id": userID}); err != nil || exists == 0 {
+			if err == nil {
+				err = mongo.ErrNoDocuments
+			}
+			return err
+		}
+		// already banned; a suspension changes nothing
+	}
+	middleware.ForgetAccountStatus(userID)
+
+	_, err = database.DB.Collection("active_proximities").UpdateMany(ctx,
+		bson.M{"user_id": userID, "expires_at": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"expires_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	closeUserChatConns(userID.Hex())
+	return nil
+}
+
+// ReinstateUser lifts a suspension or ban
+func ReinstateUser(ctx context.Context, userID primitive.ObjectID) error {
+	res, err := database.DB.Collection("users").UpdateByID(ctx, userID, bson.M{
+		"$set":   bson.M{"status": models.UserStatusActive, "updated_at": time.Now()},
+		"$unset": bson.M{"suspended_until": "", "suspension_reason": ""},
+		"$inc":   bson.M{"version": 1},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	middleware.ForgetAccountStatus(userID)
+	return nil
+}
+
+// LiftExpiredSuspensions reactivates users whose suspension ended. Expired suspensions
+// are already ignored everywhere; this only tidies the stored status.
+func LiftExpiredSuspensions(ctx context.Context, now time.Time) (int64, error) {
+	res, err := database.DB.Collection("users").UpdateMany(ctx,
+		bson.M{"status": models.UserStatusSuspended, "suspended_until": bson.M{"$lte": now}},
+		bson.M{
+			"$set":   bson.M{"status": models.UserStatusActive, "updated_at": now},
+			"$unset": bson.M{"suspended_until": "", "suspension_reason": ""},
+			"$inc":   bson.M{"version": 1},
+		})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// LiftExpiredSuspensionsEvery runs LiftExpiredSuspensions on a ticker; it never returns
+func LiftExpiredSuspensionsEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		if n, err := LiftExpiredSuspensions(ctx, time.Now()); err != nil {
+			log.Println("lifting expired suspensions:", err)
+		} else if n > 0 {
+			log.Printf("lifted %d expired suspensions", n)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxAge = 120
+
+// userAge is the user's current age, or 0 when no date of birth is known
+func userAge(user *models.User, now time.Time) int {
+	if user.DateOfBirth == nil {
+		return 0
+	}
+	return utils.AgeAt(*user.DateOfBirth, now)
+}
+
+// underage reports whether a user's date of birth puts them below the minimum age.
+// Users without one are not underage, but cannot discover or message anyone until they
+// set it (see checkDateOfBirth).
+func underage(user *models.User, now time.Time) bool {
+	return user.DateOfBirth != nil && userAge(user, now) < config.MinimumAge
+}
+
+func underageError(c *fiber.Ctx) error {
+	return c.Status(403).JSON(fiber.Map{"error": "You must be at least " + strconv.Itoa(config.MinimumAge) + " years old to use this app"})
+}
+
+// checkDateOfBirth refuses users without a date of birth on file, returning a status and
+// an error message ending in action; 0 means the user may go ahead
+func checkDateOfBirth(ctx context.Context, userID primitive.ObjectID, action string) (int, string) {
+	n, err := database.DB.Collection("users").CountDocuments(ctx, bson.M{"_id": userID, "date_of_birth": bson.M{"$ne": nil}})
+	if err != nil {
+		return 500, "Failed to fetch user"
+	}
+	if n == 0 {
+		return 403, "Set your date of birth " + action
+	}
+	return 0, ""
+}
+
+// acceptsAge reports whether age falls within user's age preference (no preference accepts everyone)
+func acceptsAge(user *models.User, age int) bool {
+	pref := user.AgePreference
+	return pref == nil || (age >= pref.Min && age <= pref.Max)
+}
+
+// agesCompatible checks both users' age preferences against each other's age
+func agesCompatible(a *models.User, b *models.User, now time.Time) bool {
+	if a.DateOfBirth == nil || b.DateOfBirth == nil {
+		return false
+	}
+	return acceptsAge(a, userAge(b, now)) && acceptsAge(b, userAge(a, now))
+}
+
+// ageCompatibilityFilter restricts a users query to adults with a known date of birth
+// who fall within viewer's age preference and whose own preference accepts viewer.
+// A nil viewer (anonymous request) only applies the minimum age.
+func ageCompatibilityFilter(viewer *models.User, now time.Time) bson.M {
+	dob := bson.M{"$lte": utils.BornNoLaterThan(config.MinimumAge, now)}
+	filter := bson.M{}
+	if viewer == nil {
+		filter["date_of_birth"] = dob
+		return filter
+	}
+	if pref := viewer.AgePreference; pref != nil {
+		dob["$lte"] = utils.BornNoLaterThan(max(pref.Min, config.MinimumAge), now)
+		dob["$gt"] = utils.BornAfter(pref.Max, now)
+	}
+	filter["date_of_birth"] = dob
+
+	viewerAge := userAge(viewer, now)
+	filter["$or"] = bson.A{
+		bson.M{"age_preference": nil},
+		bson.M{"age_preference.min": bson.M{"$lte": viewerAge}, "age_preference.max": bson.M{"$gte": viewerAge}},
+	}
+	return filter
+}
+
+// parseAgePreference validates a {"min": .., "max": ..} age range
+func parseAgePreference(value interface{}) (interface{}, error) {
+	invalid := errors.New("must be an object with whole numbers min and max, " +
+		strconv.Itoa(config.MinimumAge) + " <= min <= max <= " + strconv.Itoa(maxAge))
+	obj, ok := value.(map[string]interface{})
+	if !ok || len(obj) != 2 {
+		return nil, invalid
+	}
+	lo, okLo := obj["min"].(float64)
+	hi, okHi := obj["max"].(float64)
+	if !okLo || !okHi || lo != math.Trunc(lo) || hi != math.Trunc(hi) {
+		return nil, invalid
+	}
+	if int(lo) < config.MinimumAge || int(hi) > maxAge || lo > hi {
+		return nil, invalid
+	}
+	return &models.AgeRange{Min: int(lo), Max: int(hi)}, nil
+}
+
+// parseDateOfBirth validates a YYYY-MM-DD date of birth against the minimum age
+func parseDateOfBirth(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a date in YYYY-MM-DD format")
+	}
+	dob, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, errors.New("must be a date in YYYY-MM-DD format")
+	}
+	age := utils.AgeAt(dob, time.Now())
+	if age < config.MinimumAge {
+		return nil, errors.New("you must be at least " + strconv.Itoa(config.MinimumAge) + " years old")
+	}
+	if age > maxAge {
+		return nil, errors.New("must be a real date of birth")
+	}
+	return &dob, nil
+}
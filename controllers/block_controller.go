@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// blockedWith returns every user userID blocked or was blocked by
+func blockedWith(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+	if userID.IsZero() {
+		return ids, nil
+	}
+	cursor, err := database.DB.Collection("user_blocks").Find(ctx, bson.M{
+		"$or": bson.A{bson.M{"blocker_id": userID}, bson.M{"blocked_id": userID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var blocks []models.UserBlock
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.BlockerID == userID {
+			ids = append(ids, b.BlockedID)
+		} else {
+			ids = append(ids, b.BlockerID)
+		}
+	}
+	return ids, nil
+}
+
+// isBlocked reports whether either user blocked the other
+func isBlocked(ctx context.Context, a primitive.ObjectID, b primitive.ObjectID) (bool, error) {
+	count, err := database.DB.Collection("user_blocks").CountDocuments(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"blocker_id": a, "blocked_id": b},
+			bson.M{"blocker_id": b, "blocked_id": a},
+		},
+	})
+	return count > 0, err
+}
+
+// blockUser records the block, withdraws pending meeting requests between the two users
+// and ends their connection
+func blockUser(ctx context.Context, blocker primitive.ObjectID, blocked primitive.ObjectID, reason string) (models.UserBlock, bool, error) {
+	block := models.UserBlock{BlockerID: blocker, BlockedID: blocked, Reason: reason, CreatedAt: time.Now()}
+	res, err := database.DB.Collection("user_blocks").UpdateOne(ctx,
+		bson.M{"blocker_id": blocker, "blocked_id": blocked},
+		bson.M{"$setOnInsert": block},
+		options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return block, false, err
+	}
+	created := err == nil && res.UpsertedCount > 0
+	if !created {
+		err = database.DB.Collection("user_blocks").FindOne(ctx, bson.M{"blocker_id": blocker, "blocked_id": blocked}).Decode(&block)
+		return block, false, err
+	}
+	block.ID = res.UpsertedID.(primitive.ObjectID)
+	forgetSuggestions(blocker, blocked)
+
+	_, err = database.DB.Collection("meeting_requests").UpdateMany(ctx, bson.M{
+		"status": "pending",
+		"$or": bson.A{
+			bson.M{"requester_id": blocker, "target_user_id": blocked},
+			bson.M{"requester_id": blocked, "target_user_id": blocker},
+		},
+	}, bson.M{"$set": bson.M{"status": "deleted", "updated_at": time.Now()}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return block, true, err
+	}
+	if _, err := removeConnection(ctx, blocker, blocked, bson.M{}); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return block, true, err
+	}
+	return block, true, nil
+}
+
+// POST /users/:userId/blocks {"userId": "<hex>", "reason": "..."} - block a user.
+// Blocking someone already blocked returns the existing block.
+func BlockUser(c *fiber.Ctx) error {
+	blockerID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req struct {
+		UserID string `json:"userId"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	blockedID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+	}
+	if blockedID == blockerID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot block yourself"})
+	}
+	if reason := strings.TrimSpace(req.Reason); len(reason) > 500 {
+		return c.Status(400).JSON(fiber.Map{"error": "reason must be at most 500 characters"})
+	}
+
+	exists, err := UserExists(blockedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check user existence"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	block, created, err := blockUser(ctx, blockerID, blockedID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to block user"})
+	}
+	if !created {
+		return c.Status(200).JSON(block)
+	}
+	return c.Status(201).JSON(block)
+}
+
+// DELETE /users/:userId/blocks/:blockedId - unblock a user
+func UnblockUser(c *fiber.Ctx) error {
+	blockerID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	blockedID, err := primitive.ObjectIDFromHex(c.Params("blockedId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid blocked user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	res, err := database.DB.Collection("user_blocks").DeleteOne(ctx, bson.M{"blocker_id": blockerID, "blocked_id": blockedID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to unblock user"})
+	}
+	if res.DeletedCount == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "User is not blocked"})
+	}
+	return c.Status(200).JSON(fiber.Map{"message": "User unblocked"})
+}
+
+// GET /users/:userId/blocks - the users this user blocked, most recent first
+func GetBlockedUsers(c *fiber.Ctx) error {
+	blockerID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := database.DB.Collection("user_blocks").Find(ctx, bson.M{"blocker_id": blockerID}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch blocked users"})
+	}
+	blocks := []models.UserBlock{}
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode blocked users"})
+	}
+	return c.Status(200).JSON(blocks)
+}
@@ -3,16 +3,20 @@ package controllers
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"fast-af/config"
 	"fast-af/database"
+	"fast-af/middleware"
 	"fast-af/models"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // ChatConn tracks a user's websocket connection in a chat window
@@ -20,25 +24,86 @@ type ChatConn struct {
 	UserID       string
 	ChatWindowID string
 	Conn         *websocket.Conn
+	writeMu      sync.Mutex           // websocket connections allow one concurrent writer
+	watching     []primitive.ObjectID // users whose presence this socket subscribed to, guarded by presenceMu
 }
 
-// chatWindowID -> list of *ChatConn
+// WriteMessage sends a frame on the connection, serialising concurrent broadcasters
+func (cc *ChatConn) WriteMessage(messageType int, data []byte) error {
+	cc.writeMu.Lock()
+	defer cc.writeMu.Unlock()
+	return cc.Conn.WriteMessage(messageType, data)
+}
+
+// chatWindowID -> list of *ChatConn, guarded by chatClientsMu
 var chatWindowClients = make(map[string][]*ChatConn)
+var chatClientsMu sync.RWMutex
+
+// chatClients returns a snapshot of the connections in a chat window
+func chatClients(chatWindowId string) []*ChatConn {
+	chatClientsMu.RLock()
+	defer chatClientsMu.RUnlock()
+	return append([]*ChatConn(nil), chatWindowClients[chatWindowId]...)
+}
 
-// WebSocket handler logic for chat window
+// closeUserChatConns disconnects every chat socket userId holds on this server
+func closeUserChatConns(userId string) {
+	chatClientsMu.RLock()
+	var conns []*ChatConn
+	for _, clients := range chatWindowClients {
+		for _, cc := range clients {
+			if cc.UserID == userId {
+				conns = append(conns, cc)
+			}
+		}
+	}
+	chatClientsMu.RUnlock()
+	for _, cc := range conns {
+		cc.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account suspended"))
+		cc.Conn.Close()
+	}
+}
+
+// WebSocket handler logic for chat window. Only participants of the window may connect.
 func HandleChatWebSocket(conn *websocket.Conn, userId string, chatWindowId string) {
+	if !isChatParticipant(chatWindowId, userId) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "not a participant of this chat window"))
+		conn.Close()
+		return
+	}
+	if !hasDateOfBirth(userId) {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "set your date of birth before sending messages"))
+		conn.Close()
+		return
+	}
 	chatConn := &ChatConn{UserID: userId, ChatWindowID: chatWindowId, Conn: conn}
 	// Register connection
+	chatClientsMu.Lock()
 	chatWindowClients[chatWindowId] = append(chatWindowClients[chatWindowId], chatConn)
+	chatClientsMu.Unlock()
+	// presence is tracked per user across all their sockets
+	userObjectID, idErr := primitive.ObjectIDFromHex(userId)
+	if idErr == nil {
+		presenceConnected(userObjectID, time.Now())
+	}
 	defer func() {
 		// Remove connection on close
+		chatClientsMu.Lock()
 		var updated []*ChatConn
 		for _, cc := range chatWindowClients[chatWindowId] {
 			if cc.Conn != conn {
 				updated = append(updated, cc)
 			}
 		}
-		chatWindowClients[chatWindowId] = updated
+		if len(updated) == 0 {
+			delete(chatWindowClients, chatWindowId)
+		} else {
+			chatWindowClients[chatWindowId] = updated
+		}
+		chatClientsMu.Unlock()
+		if idErr == nil {
+			presenceDisconnected(userObjectID, chatConn, time.Now())
+		}
 		conn.Close()
 	}()
 
@@ -52,8 +117,24 @@ func HandleChatWebSocket(conn *websocket.Conn, userId string, chatWindowId strin
 			log.Println("read error:", err)
 			break
 		}
-		// Fetch valid participants from DB
+		senderID, _ := primitive.ObjectIDFromHex(userId)
+		// suspensions applied elsewhere (admin CLI, another server) end the socket here
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		sender, err := middleware.AccountStatus(ctx, senderID)
+		cancel()
+		if err == nil && middleware.AccountRestriction(&sender, time.Now()) != "" {
+			chatConn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account suspended"))
+			break
+		}
+		if idErr == nil {
+			// heartbeats and presence subscriptions are not chat messages
+			if mt == websocket.TextMessage && handlePresenceMessage(chatConn, userObjectID, msg) {
+				continue
+			}
+			presenceActive(userObjectID, time.Now())
+		}
+		// Fetch valid participants from DB
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 		var chatWindow models.ChatWindow
 		chatWindowObjID, err := primitive.ObjectIDFromHex(chatWindowId)
 		if err != nil {
@@ -67,14 +148,26 @@ func HandleChatWebSocket(conn *websocket.Conn, userId string, chatWindowId strin
 			log.Println("DB error fetching chat window:", err)
 			continue
 		}
+		// recipients who blocked (or were blocked by) the sender never get their messages;
+		// in 1-1 windows neither do recipients who no longer accept messages from them
 		validParticipants := make(map[string]bool)
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 		for _, pid := range chatWindow.ParticipantIDs {
-			validParticipants[pid.Hex()] = true
+			var allowed bool
+			if chatWindow.IsGroup {
+				blocked, err := isBlocked(ctx, senderID, pid)
+				allowed = err == nil && !blocked
+			} else {
+				ok, err := canMessage(ctx, senderID, pid)
+				allowed = err == nil && ok
+			}
+			validParticipants[pid.Hex()] = allowed
 		}
+		cancel()
 		// Broadcast only to valid participants
-		for _, cc := range chatWindowClients[chatWindowId] {
+		for _, cc := range chatClients(chatWindowId) {
 			if cc.Conn != conn && validParticipants[cc.UserID] {
-				if err := cc.Conn.WriteMessage(mt, msg); err != nil {
+				if err := cc.WriteMessage(mt, msg); err != nil {
 					log.Println("broadcast error:", err)
 				}
 			}
@@ -82,6 +175,34 @@ func HandleChatWebSocket(conn *websocket.Conn, userId string, chatWindowId strin
 	}
 }
 
+// isChatParticipant reports whether userId is a participant of chatWindowId
+func isChatParticipant(chatWindowId string, userId string) bool {
+	windowID, err := primitive.ObjectIDFromHex(chatWindowId)
+	if err != nil {
+		return false
+	}
+	userID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	count, err := database.DB.Collection("chat_windows").CountDocuments(ctx, bson.M{"_id": windowID, "participant_ids": userID})
+	return err == nil && count > 0
+}
+
+// hasDateOfBirth reports whether userId has a date of birth on file, see checkDateOfBirth
+func hasDateOfBirth(userId string) bool {
+	userID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	status, _ := checkDateOfBirth(ctx, userID, "")
+	return status == 0
+}
+
 func ChatWebSocket(c *fiber.Ctx) error {
 	userId := c.Params("userId")
 	chatWindowId := c.Query("chatWindowId")
@@ -113,6 +234,16 @@ func CreateChatWindow(c *fiber.Ctx) error {
 		}
 		pids = append(pids, oid)
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	// the caller opens the window (v1 has no caller: the first participant does)
+	initiator := viewerID(c)
+	if initiator.IsZero() {
+		initiator = pids[0]
+	}
+	if status, msg := checkCanMessage(ctx, initiator, pids); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
 	now := time.Now()
 	chatWindow := models.ChatWindow{
 		ParticipantIDs: pids,
@@ -120,8 +251,6 @@ func CreateChatWindow(c *fiber.Ctx) error {
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
-	defer cancel()
 	res, err := database.DB.Collection("chat_windows").InsertOne(ctx, chatWindow)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error creating chat window"})
@@ -130,6 +259,113 @@ func CreateChatWindow(c *fiber.Ctx) error {
 	return c.Status(201).JSON(chatWindow)
 }
 
+// Fetch a single chat window. With ?userId= (always set on v2) only its participants can see it.
+func GetChatWindow(c *fiber.Ctx) error {
+	oid, err := primitive.ObjectIDFromHex(c.Params("chatWindowId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
+	}
+	filter := bson.M{"_id": oid}
+	if userId := c.Query("userId"); userId != "" {
+		userID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+		}
+		filter["participant_ids"] = userID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	var chatWindow models.ChatWindow
+	if err := database.DB.Collection("chat_windows").FindOne(ctx, filter).Decode(&chatWindow); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
+	if notModified(c, chatWindowETag(chatWindow)) {
+		return c.SendStatus(304)
+	}
+	return c.Status(200).JSON(chatWindow)
+}
+
+// Update a chat window's participants or group flag. Only participants (userId query param)
+// may edit it, and the update is conditional on If-Match or a "version" field.
+func UpdateChatWindow(c *fiber.Ctx) error {
+	oid, err := primitive.ObjectIDFromHex(c.Params("chatWindowId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
+	}
+	userID, err := primitive.ObjectIDFromHex(c.Query("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+	}
+	var req struct {
+		ParticipantIDs []string `json:"participantIds"`
+		IsGroup        *bool    `json:"isGroup"`
+		Version        *int64   `json:"version"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if req.ParticipantIDs != nil {
+		if len(req.ParticipantIDs) < 2 {
+			return c.Status(400).JSON(fiber.Map{"error": "At least 2 participants required"})
+		}
+		var pids []primitive.ObjectID
+		for _, id := range req.ParticipantIDs {
+			pid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid participant ID: " + id})
+			}
+			pids = append(pids, pid)
+		}
+		set["participant_ids"] = pids
+	}
+	if req.IsGroup != nil {
+		set["is_group"] = *req.IsGroup
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	var current models.ChatWindow
+	err = database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": oid, "participant_ids": userID}).Decode(&current)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
+	if !preconditionHolds(c, chatWindowETag(current), current.Version, req.Version) {
+		return preconditionFailed(c, chatWindowETag(current), current)
+	}
+	if pids, ok := set["participant_ids"].([]primitive.ObjectID); ok {
+		var added []primitive.ObjectID
+		for _, pid := range pids {
+			if !containsObjectID(current.ParticipantIDs, pid) {
+				added = append(added, pid)
+			}
+		}
+		if status, msg := checkCanMessage(ctx, userID, added); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+	}
+
+	filter := bson.M{"_id": oid, "version": versionFilter(current.Version)}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.ChatWindow
+	err = database.DB.Collection("chat_windows").FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		if err := database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": oid}).Decode(&current); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+		}
+		return preconditionFailed(c, chatWindowETag(current), current)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error updating chat window"})
+	}
+
+	c.Set(fiber.HeaderETag, chatWindowETag(updated))
+	return c.Status(200).JSON(updated)
+}
+
 // Send a new message (WebSocket recommended, but REST fallback)
 func SendMessage(c *fiber.Ctx) error {
 	var req struct {
@@ -148,14 +384,26 @@ func SendMessage(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	var chatWindow models.ChatWindow
+	if err := database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": chatWindowObjID, "participant_ids": userID}).Decode(&chatWindow); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
+	// in 1-1 chats the other side may have stopped accepting messages since the window was opened
+	var recipients []primitive.ObjectID
+	if !chatWindow.IsGroup {
+		recipients = chatWindow.ParticipantIDs
+	}
+	if status, msg := checkCanMessage(ctx, userID, recipients); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
 	chat := models.Chat{
 		ChatWindowID: chatWindowObjID,
 		Msg:          req.Msg,
 		CreatedBy:    userID,
 		CreatedAt:    time.Now(),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
-	defer cancel()
 	res, err := database.DB.Collection("chats").InsertOne(ctx, chat)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error sending message"})
@@ -164,16 +412,24 @@ func SendMessage(c *fiber.Ctx) error {
 	return c.Status(201).JSON(chat)
 }
 
-// Delete a message
+// Delete a message. With ?userId= (always set on v2) only its sender may delete it.
 func DeleteMessage(c *fiber.Ctx) error {
 	msgID := c.Params("msgId")
 	oid, err := primitive.ObjectIDFromHex(msgID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid message ID"})
 	}
+	filter := bson.M{"_id": oid}
+	if userId := c.Query("userId"); userId != "" {
+		userID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+		}
+		filter["user_id"] = userID
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
-	res, err := database.DB.Collection("chats").DeleteOne(ctx, bson.M{"_id": oid})
+	res, err := database.DB.Collection("chats").DeleteOne(ctx, filter)
 	if err != nil || res.DeletedCount == 0 {
 		return c.Status(500).JSON(fiber.Map{"error": "Error deleting message or not found"})
 	}
@@ -190,7 +446,7 @@ func BlockChat(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
-	_, err := primitive.ObjectIDFromHex(req.ChatWindowID)
+	chatWindowID, err := primitive.ObjectIDFromHex(req.ChatWindowID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
 	}
@@ -198,16 +454,32 @@ func BlockChat(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid restrictedBy"})
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	var chatWindow models.ChatWindow
+	if err := database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": chatWindowID, "participant_ids": restrictedBy}).Decode(&chatWindow); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
 	restriction := models.ChatRestriction{
+		ChatWindowID:    chatWindowID,
 		RestrictionType: req.RestrictionType,
 		RestrictedBy:    restrictedBy,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
-	defer cancel()
 	_, err = database.DB.Collection("chat_restrictions").InsertOne(ctx, restriction)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error blocking chat"})
 	}
+	// blocking a 1-1 chat blocks the other person everywhere
+	if !chatWindow.IsGroup {
+		for _, pid := range chatWindow.ParticipantIDs {
+			if pid == restrictedBy {
+				continue
+			}
+			if _, _, err := blockUser(ctx, restrictedBy, pid, "blocked from chat"); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Error blocking user"})
+			}
+		}
+	}
 	return c.Status(201).JSON(fiber.Map{"message": "Chat blocked"})
 }
 
@@ -233,13 +505,17 @@ func GetChatWindowsForUser(c *fiber.Ctx) error {
 	return c.Status(200).JSON(chatWindows)
 }
 
-// Fetch all messages for a chat window
+// Fetch all messages for a chat window. With ?userId= (always set on v2) only participants
+// may read them.
 func GetMessagesForChatWindow(c *fiber.Ctx) error {
 	chatWindowId := c.Params("chatWindowId")
 	oid, err := primitive.ObjectIDFromHex(chatWindowId)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
 	}
+	if userId := c.Query("userId"); userId != "" && !isChatParticipant(chatWindowId, userId) {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 	cursor, err := database.DB.Collection("chats").Find(ctx, bson.M{"chat_window_id": oid})
@@ -252,3 +528,106 @@ func GetMessagesForChatWindow(c *fiber.Ctx) error {
 	}
 	return c.Status(200).JSON(messages)
 }
+
+// checkCanMessage requires sender to have a date of birth and applies each recipient's
+// "who can message me" setting to sender, returning a status and error message for the
+// first check that refuses
+func checkCanMessage(ctx context.Context, sender primitive.ObjectID, recipients []primitive.ObjectID) (int, string) {
+	if status, msg := checkDateOfBirth(ctx, sender, "before sending messages"); status != 0 {
+		return status, msg
+	}
+	for _, recipient := range recipients {
+		ok, err := canMessage(ctx, sender, recipient)
+		if err != nil {
+			return 500, "Error checking chat settings"
+		}
+		if !ok {
+			return 403, "User " + recipient.Hex() + " does not accept messages from you"
+		}
+	}
+	return 0, ""
+}
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// POST /chat/windows/:chatWindowId/read?userId= - mark the window as read up to now
+func MarkChatWindowRead(c *fiber.Ctx) error {
+	oid, err := primitive.ObjectIDFromHex(c.Params("chatWindowId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
+	}
+	userID, err := primitive.ObjectIDFromHex(c.Query("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	count, err := database.DB.Collection("chat_windows").CountDocuments(ctx, bson.M{"_id": oid, "participant_ids": userID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching chat window"})
+	}
+	if count == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
+	read := models.ChatRead{ChatWindowID: oid, UserID: userID, ReadAt: time.Now()}
+	_, err = database.DB.Collection("chat_reads").UpdateOne(ctx,
+		bson.M{"chat_window_id": oid, "user_id": userID},
+		bson.M{"$set": bson.M{"read_at": read.ReadAt}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error marking chat window read"})
+	}
+	return c.Status(200).JSON(read)
+}
+
+// GET /chat/windows/:chatWindowId/reads?userId= - when the other participants last read the window.
+// Read receipts are mutual: users who turned them off neither share nor see them.
+func GetChatWindowReads(c *fiber.Ctx) error {
+	oid, err := primitive.ObjectIDFromHex(c.Params("chatWindowId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid chatWindowId"})
+	}
+	userID, err := primitive.ObjectIDFromHex(c.Query("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	var chatWindow models.ChatWindow
+	if err := database.DB.Collection("chat_windows").FindOne(ctx, bson.M{"_id": oid, "participant_ids": userID}).Decode(&chatWindow); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Chat window not found"})
+	}
+
+	settings, err := loadSettingsFor(ctx, chatWindow.ParticipantIDs)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching chat settings"})
+	}
+	reads := []models.ChatRead{}
+	if !settings[userID].Chat.ReadReceipts {
+		return c.Status(200).JSON(reads)
+	}
+	var sharing []primitive.ObjectID
+	for _, pid := range chatWindow.ParticipantIDs {
+		if pid != userID && settings[pid].Chat.ReadReceipts {
+			sharing = append(sharing, pid)
+		}
+	}
+	if len(sharing) == 0 {
+		return c.Status(200).JSON(reads)
+	}
+	cursor, err := database.DB.Collection("chat_reads").Find(ctx, bson.M{"chat_window_id": oid, "user_id": bson.M{"$in": sharing}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching read receipts"})
+	}
+	if err := cursor.All(ctx, &reads); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error decoding read receipts"})
+	}
+	return c.Status(200).JSON(reads)
+}
@@ -0,0 +1,484 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// connectionRetryAfter is how long someone whose request was declined waits to ask again
+const connectionRetryAfter = 30 * 24 * time.Hour
+
+const maxConnectionMessage = 300
+
+// connectionPair identifies the connection document of two users, whichever asked
+func connectionPair(a primitive.ObjectID, b primitive.ObjectID) (string, []primitive.ObjectID) {
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	return a.Hex() + ":" + b.Hex(), []primitive.ObjectID{a, b}
+}
+
+// usersConnectedWith returns which of candidates (nil = anyone) are connected to userID
+func usersConnectedWith(ctx context.Context, userID primitive.ObjectID, candidates []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	connected := map[primitive.ObjectID]bool{}
+	if userID.IsZero() || (candidates != nil && len(candidates) == 0) {
+		return connected, nil
+	}
+	filter := bson.M{"user_ids": userID, "status": models.ConnectionAccepted}
+	if candidates != nil {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"user_ids": bson.M{"$in": candidates}}}}
+	}
+	ids, err := database.DB.Collection("connections").Distinct(ctx, "user_ids", filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if oid, ok := id.(primitive.ObjectID); ok && oid != userID {
+			connected[oid] = true
+		}
+	}
+	return connected, nil
+}
+
+// isConnected reports whether the two users accepted a connection
+func isConnected(ctx context.Context, a primitive.ObjectID, b primitive.ObjectID) (bool, error) {
+	key, _ := connectionPair(a, b)
+	count, err := database.DB.Collection("connections").CountDocuments(ctx, bson.M{"pair_key": key, "status": models.ConnectionAccepted})
+	return count > 0, err
+}
+
+// mutualConnectionCounts counts, for each of candidates, the connections they share with viewer
+func mutualConnectionCounts(ctx context.Context, viewer primitive.ObjectID, candidates []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	counts := map[primitive.ObjectID]int{}
+	if viewer.IsZero() || len(candidates) == 0 {
+		return counts, nil
+	}
+	mine, err := usersConnectedWith(ctx, viewer, nil)
+	if err != nil || len(mine) == 0 {
+		return counts, err
+	}
+	viewerConnections := make([]primitive.ObjectID, 0, len(mine))
+	for id := range mine {
+		viewerConnections = append(viewerConnections, id)
+	}
+	var rows []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int                `bson:"count"`
+	}
+	err = aggregateAll(ctx, "connections", bson.A{
+		bson.M{"$match": bson.M{"status": models.ConnectionAccepted, "user_ids": bson.M{"$in": candidates}}},
+		bson.M{"$project": bson.M{"user": "$user_ids", "pair": "$user_ids"}},
+		bson.M{"$unwind": "$user"},
+		bson.M{"$match": bson.M{"user": bson.M{"$in": candidates}}},
+		bson.M{"$project": bson.M{"user": 1, "other": bson.M{"$arrayElemAt": bson.A{bson.M{"$setDifference": bson.A{"$pair", bson.A{"$user"}}}, 0}}}},
+		bson.M{"$match": bson.M{"other": bson.M{"$in": viewerConnections}}},
+		bson.M{"$group": bson.M{"_id": "$user", "count": bson.M{"$sum": 1}}},
+	}, &rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.ID] = r.Count
+	}
+	return counts, nil
+}
+
+// presentMutualConnections sets mutualConnections on users other than the viewer
+func presentMutualConnections(ctx context.Context, users []models.User, viewer primitive.ObjectID) error {
+	ids := make([]primitive.ObjectID, 0, len(users))
+	for _, u := range users {
+		if u.ID != viewer {
+			ids = append(ids, u.ID)
+		}
+	}
+	counts, err := mutualConnectionCounts(ctx, viewer, ids)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if users[i].ID != viewer && !viewer.IsZero() {
+			n := counts[users[i].ID]
+			users[i].MutualConnections = &n
+		}
+	}
+	return nil
+}
+
+// adjustConnectionCounts changes connection_count on both users of a connection
+func adjustConnectionCounts(ctx context.Context, userIDs []primitive.ObjectID, delta int) error {
+	_, err := database.DB.Collection("users").UpdateMany(ctx, bson.M{"_id": bson.M{"$in": userIDs}}, bson.M{
+		"$inc": bson.M{"connection_count": delta, "version": 1},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// removeConnection deletes the connection (or request) between two users that matches
+// filter, keeping the connection counts in step. mongo.ErrNoDocuments means there was none.
+func removeConnection(ctx context.Context, a primitive.ObjectID, b primitive.ObjectID, filter bson.M) (*models.Connection, error) {
+	key, _ := connectionPair(a, b)
+	filter["pair_key"] = key
+	var conn models.Connection
+	if err := database.DB.Collection("connections").FindOneAndDelete(ctx, filter).Decode(&conn); err != nil {
+		return nil, err
+	}
+	forgetSuggestions(conn.UserIDs...)
+	if conn.Status == models.ConnectionAccepted {
+		if err := adjustConnectionCounts(ctx, conn.UserIDs, -1); err != nil {
+			return &conn, err
+		}
+	}
+	return &conn, nil
+}
+
+// acceptConnection accepts the pending request addressed to userID from otherID
+func acceptConnection(ctx context.Context, userID primitive.ObjectID, otherID primitive.ObjectID) (*models.Connection, error) {
+	key, _ := connectionPair(userID, otherID)
+	now := time.Now()
+	var conn models.Connection
+	err := database.DB.Collection("connections").FindOneAndUpdate(ctx,
+		bson.M{"pair_key": key, "status": models.ConnectionPending, "addressee_id": userID},
+		bson.M{
+			"$set": bson.M{"status": models.ConnectionAccepted, "accepted_at": now, "updated_at": now},
+			"$inc": bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&conn)
+	if err != nil {
+		return nil, err
+	}
+	forgetSuggestions(conn.UserIDs...)
+	if err := adjustConnectionCounts(ctx, conn.UserIDs, 1); err != nil {
+		return &conn, err
+	}
+	return &conn, nil
+}
+
+// connectionUsers loads and presents the other user of each connection, keyed by id
+func connectionUsers(ctx context.Context, conns []models.Connection, userID primitive.ObjectID) (map[primitive.ObjectID]models.User, error) {
+	ids := make([]primitive.ObjectID, 0, len(conns))
+	for _, conn := range conns {
+		for _, id := range conn.UserIDs {
+			if id != userID {
+				ids = append(ids, id)
+			}
+		}
+	}
+	users := map[primitive.ObjectID]models.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.User
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	presentUsers(found, userID)
+	if err := presentMutualConnections(ctx, found, userID); err != nil {
+		return nil, err
+	}
+	if err := presentPresence(ctx, found, userID); err != nil {
+		return nil, err
+	}
+	for _, u := range found {
+		users[u.ID] = u
+	}
+	return users, nil
+}
+
+// connectionEntry is a connection as listed to one of its users, with the other user
+type connectionEntry struct {
+	models.Connection
+	User *models.User `json:"user,omitempty"`
+}
+
+// listConnections pages through userID's connections matching filter
+func listConnections(c *fiber.Ctx, userID primitive.ObjectID, filter bson.M, sortField string) error {
+	page, limit, err := pageParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	total, err := database.DB.Collection("connections").CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch connections"})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: sortField, Value: -1}, {Key: "_id", Value: -1}}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cursor, err := database.DB.Collection("connections").Find(ctx, filter, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch connections"})
+	}
+	var conns []models.Connection
+	if err := cursor.All(ctx, &conns); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode connections"})
+	}
+	users, err := connectionUsers(ctx, conns, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch connected users"})
+	}
+	entries := make([]connectionEntry, len(conns))
+	for i, conn := range conns {
+		entries[i] = connectionEntry{Connection: conn}
+		for _, id := range conn.UserIDs {
+			if u, ok := users[id]; ok && id != userID {
+				entries[i].User = &u
+			}
+		}
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"results": entries,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// POST /users/:userId/connections {"userId": "<hex>", "message": "..."} - ask to connect.
+// Asking someone who already asked you accepts their request. After a decline the same
+// person can ask again once connectionRetryAfter has passed.
+func RequestConnection(c *fiber.Ctx) error {
+	requesterID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req struct {
+		UserID  string `json:"userId"`
+		Message string `json:"message"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	addresseeID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+	}
+	if addresseeID == requesterID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot connect with yourself"})
+	}
+	message := strings.TrimSpace(req.Message)
+	if len(message) > maxConnectionMessage {
+		return c.Status(400).JSON(fiber.Map{"error": "message must be at most 300 characters"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	addressee, err := FindUser(ctx, addresseeID)
+	if err != nil || middleware.AccountRestriction(addressee, time.Now()) != "" {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if blocked, err := isBlocked(ctx, requesterID, addresseeID); err != nil || blocked {
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check blocks"})
+		}
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	key, pair := connectionPair(requesterID, addresseeID)
+	now := time.Now()
+	conn := models.Connection{
+		PairKey:     key,
+		UserIDs:     pair,
+		RequesterID: requesterID,
+		AddresseeID: addresseeID,
+		Message:     message,
+		Status:      models.ConnectionPending,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	res, err := database.DB.Collection("connections").UpdateOne(ctx,
+		bson.M{"pair_key": key},
+		bson.M{"$setOnInsert": conn},
+		options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to send connection request"})
+	}
+	if err == nil && res.UpsertedCount > 0 {
+		conn.ID = res.UpsertedID.(primitive.ObjectID)
+		forgetSuggestions(requesterID)
+		return c.Status(201).JSON(conn)
+	}
+
+	var existing models.Connection
+	if err := database.DB.Collection("connections").FindOne(ctx, bson.M{"pair_key": key}).Decode(&existing); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to send connection request"})
+	}
+	switch {
+	case existing.Status == models.ConnectionAccepted:
+		return c.Status(409).JSON(fiber.Map{"error": "You are already connected"})
+	case existing.Status == models.ConnectionPending && existing.RequesterID == requesterID:
+		return c.Status(200).JSON(existing)
+	case existing.Status == models.ConnectionPending:
+		// they asked first: asking back accepts
+		accepted, err := acceptConnection(ctx, requesterID, addresseeID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(409).JSON(fiber.Map{"error": "The connection request changed, try again"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to accept connection request"})
+		}
+		return c.Status(200).JSON(accepted)
+	case existing.RequesterID == requesterID && now.Before(existing.UpdatedAt.Add(connectionRetryAfter)):
+		retryAt := existing.UpdatedAt.Add(connectionRetryAfter)
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "You can ask this user to connect again after " + retryAt.Format(time.RFC3339),
+			"retryAt": retryAt,
+		})
+	}
+
+	// a declined request is asked again, possibly the other way round
+	var renewed models.Connection
+	err = database.DB.Collection("connections").FindOneAndUpdate(ctx,
+		bson.M{"_id": existing.ID, "version": versionFilter(existing.Version)},
+		bson.M{
+			"$set": bson.M{
+				"requester_id": requesterID,
+				"addressee_id": addresseeID,
+				"message":      message,
+				"status":       models.ConnectionPending,
+				"created_at":   now,
+				"updated_at":   now,
+			},
+			"$inc": bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&renewed)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(fiber.Map{"error": "The connection request changed, try again"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to send connection request"})
+	}
+	return c.Status(201).JSON(renewed)
+}
+
+// POST /users/:userId/connections/:otherId/accept - accept otherId's request
+func AcceptConnection(c *fiber.Ctx) error {
+	userID, otherID, err := connectionParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	conn, err := acceptConnection(ctx, userID, otherID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(fiber.Map{"error": "No pending connection request from this user"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to accept connection request"})
+	}
+	return c.Status(200).JSON(conn)
+}
+
+// POST /users/:userId/connections/:otherId/decline - decline otherId's request
+func DeclineConnection(c *fiber.Ctx) error {
+	userID, otherID, err := connectionParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	key, _ := connectionPair(userID, otherID)
+	now := time.Now()
+	var conn models.Connection
+	err = database.DB.Collection("connections").FindOneAndUpdate(ctx,
+		bson.M{"pair_key": key, "status": models.ConnectionPending, "addressee_id": userID},
+		bson.M{"$set": bson.M{"status": models.ConnectionDeclined, "updated_at": now}, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&conn)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(fiber.Map{"error": "No pending connection request from this user"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decline connection request"})
+	}
+	return c.Status(200).JSON(conn)
+}
+
+// DELETE /users/:userId/connections/:otherId - remove a connection or withdraw a request
+func RemoveConnection(c *fiber.Ctx) error {
+	userID, otherID, err := connectionParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	conn, err := removeConnection(ctx, userID, otherID, bson.M{"$or": bson.A{
+		bson.M{"status": models.ConnectionAccepted},
+		bson.M{"status": models.ConnectionPending, "requester_id": userID},
+	}})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(fiber.Map{"error": "You are not connected with this user"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove connection"})
+	}
+	if conn.Status == models.ConnectionPending {
+		return c.Status(200).JSON(fiber.Map{"message": "Connection request withdrawn"})
+	}
+	return c.Status(200).JSON(fiber.Map{"message": "Connection removed"})
+}
+
+// GET /users/:userId/connections?page=&limit= - the user's connections, newest first
+func GetConnections(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	return listConnections(c, userID, bson.M{"user_ids": userID, "status": models.ConnectionAccepted}, "accepted_at")
+}
+
+// GET /users/:userId/connections/requests?direction=incoming|outgoing&page=&limit=
+// Pending connection requests, newest first. Declined requests are not listed.
+func GetConnectionRequests(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	filter := bson.M{"status": models.ConnectionPending}
+	switch c.Query("direction", "incoming") {
+	case "incoming":
+		filter["addressee_id"] = userID
+	case "outgoing":
+		filter["requester_id"] = userID
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "direction must be incoming or outgoing"})
+	}
+	return listConnections(c, userID, filter, "created_at")
+}
+
+// connectionParams reads the acting user from :userId and the other user from :otherId
+func connectionParams(c *fiber.Ctx) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return userID, userID, errors.New("Invalid user ID")
+	}
+	otherID, err := primitive.ObjectIDFromHex(c.Params("otherId"))
+	if err != nil {
+		return userID, otherID, errors.New("Invalid other user ID")
+	}
+	return userID, otherID, nil
+}
@@ -1,9 +1,16 @@
 package controllers
 
 import (
+	"fast-af/middleware"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 func Ping(c *fiber.Ctx) error {
 	return c.SendString("Pong!")
 }
+
+// GET /api/v2/deprecations - call counts of deprecated v1 routes since startup
+func GetDeprecatedRouteUsage(c *fiber.Ctx) error {
+	return c.JSON(middleware.DeprecatedUsage())
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fast-af/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDevLoginRefusesRemoteClients(t *testing.T) {
+	enabled := config.EnableDevLogin
+	config.EnableDevLogin = true
+	defer func() { config.EnableDevLogin = enabled }()
+
+	app := fiber.New()
+	app.Post("/auth/dev/login", DevLogin)
+	for name, header := range map[string]string{"remote": "", "proxied": fiber.HeaderXForwardedFor} {
+		req := httptest.NewRequest("POST", "/auth/dev/login", strings.NewReader(`{"email":"alice@example.com"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if header != "" {
+			req.Header.Set(header, "127.0.0.1")
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 404 {
+			t.Errorf("%s client: status %d, want 404", name, resp.StatusCode)
+		}
+	}
+}
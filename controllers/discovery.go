@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"fast-af/database"
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// usersSharingInterests returns which of candidates share at least one interest with userID
+func usersSharingInterests(ctx context.Context, userID primitive.ObjectID, candidates []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	shared := map[primitive.ObjectID]bool{}
+	if userID.IsZero() || len(candidates) == 0 {
+		return shared, nil
+	}
+	interestIDs, err := database.DB.Collection("user_interests").Distinct(ctx, "interest_id", bson.M{"user_id": userID})
+	if err != nil || len(interestIDs) == 0 {
+		return shared, err
+	}
+	userIDs, err := database.DB.Collection("user_interests").Distinct(ctx, "user_id", bson.M{
+		"user_id":     bson.M{"$in": candidates},
+		"interest_id": bson.M{"$in": interestIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			shared[oid] = true
+		}
+	}
+	return shared, nil
+}
+
+// usersChattedWith returns which of candidates (nil = anyone) share a chat window with userID
+func usersChattedWith(ctx context.Context, userID primitive.ObjectID, candidates []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	chatted := map[primitive.ObjectID]bool{}
+	if userID.IsZero() || (candidates != nil && len(candidates) == 0) {
+		return chatted, nil
+	}
+	filter := bson.M{"participant_ids": userID}
+	if candidates != nil {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"participant_ids": bson.M{"$in": candidates}}}}
+	}
+	participants, err := database.DB.Collection("chat_windows").Distinct(ctx, "participant_ids", filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range participants {
+		if oid, ok := id.(primitive.ObjectID); ok && oid != userID {
+			chatted[oid] = true
+		}
+	}
+	return chatted, nil
+}
+
+// restrictedVisibilities are the discovery visibilities that hide a user from some viewers
+var restrictedVisibilities = bson.A{
+	models.VisibilitySharedInterests, models.VisibilityChatted, models.VisibilityConnections, models.VisibilityHidden,
+}
+
+// hiddenFrom lists which of candidates are hidden from viewer: suspended or banned users, users
+// blocked by or blocking viewer, hidden users, users visible only to people sharing an interest or a chat with them
+// or to their connections when viewer does not, and, for nearby listings, users who turned off nearby visibility.
+// Users without settings are visible to everyone; an anonymous (zero) viewer shares nothing.
+// Listings that are not limited to known candidates filter with discoveryStages instead.
+func hiddenFrom(ctx context.Context, viewer primitive.ObjectID, candidates []primitive.ObjectID, nearby bool) ([]primitive.ObjectID, error) {
+	if len(candidates) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	restricted := bson.A{bson.M{"discovery.visibility": bson.M{"$in": restrictedVisibilities}}}
+	if nearby {
+		restricted = append(restricted, bson.M{"discovery.visible_in_nearby": false})
+	}
+	cursor, err := database.DB.Collection("user_settings").Find(ctx, bson.M{"_id": bson.M{"$in": candidates}, "$or": restricted})
+	if err != nil {
+		return nil, err
+	}
+	var settings []models.UserSettings
+	if err := cursor.All(ctx, &settings); err != nil {
+		return nil, err
+	}
+
+	// blocks hide both users from each other regardless of their settings
+	hidden, err := blockedWith(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+	suspended, err := restrictedAccounts(ctx, candidates, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	hidden = append(hidden, suspended...)
+	byVisibility := hiddenBySettings(settings, viewer, nearby)
+	hidden = append(hidden, byVisibility.hidden...)
+	shared, err := usersSharingInterests(ctx, viewer, byVisibility.needShared)
+	if err != nil {
+		return nil, err
+	}
+	chatted, err := usersChattedWith(ctx, viewer, byVisibility.needChatted)
+	if err != nil {
+		return nil, err
+	}
+	connected, err := usersConnectedWith(ctx, viewer, byVisibility.needConnected)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range byVisibility.needShared {
+		if !shared[id] {
+			hidden = append(hidden, id)
+		}
+	}
+	for _, id := range byVisibility.needChatted {
+		if !chatted[id] {
+			hidden = append(hidden, id)
+		}
+	}
+	for _, id := range byVisibility.needConnected {
+		if !connected[id] {
+			hidden = append(hidden, id)
+		}
+	}
+	return hidden, nil
+}
+
+// settingsVisibility sorts users by what their discovery settings require of a viewer
+type settingsVisibility struct {
+	hidden        []primitive.ObjectID // hidden whatever the viewer
+	needShared    []primitive.ObjectID // visible if the viewer shares an interest with them
+	needChatted   []primitive.ObjectID // visible if the viewer shares a chat with them
+	needConnected []primitive.ObjectID // visible if the viewer is connected to them
+}
+
+// hiddenBySettings applies the discovery settings of users to viewer, see hiddenFrom
+func hiddenBySettings(settings []models.UserSettings, viewer primitive.ObjectID, nearby bool) settingsVisibility {
+	var v settingsVisibility
+	for _, s := range settings {
+		if s.UserID == viewer {
+			continue // people always see themselves
+		}
+		switch {
+		case s.Discovery.Visibility == models.VisibilityHidden:
+			v.hidden = append(v.hidden, s.UserID)
+		case nearby && !s.Discovery.VisibleInNearby:
+			v.hidden = append(v.hidden, s.UserID)
+		case s.Discovery.Visibility == models.VisibilitySharedInterests:
+			v.needShared = append(v.needShared, s.UserID)
+		case s.Discovery.Visibility == models.VisibilityChatted:
+			v.needChatted = append(v.needChatted, s.UserID)
+		case s.Discovery.Visibility == models.VisibilityConnections:
+			v.needConnected = append(v.needConnected, s.UserID)
+		}
+	}
+	return v
+}
+
+// discoveryStages returns aggregation stages that drop the users hidden from viewer (see
+// hiddenFrom) from a pipeline over the users collection, so listings of everyone are
+// filtered in the query. The ids they embed are bounded by the viewer's own blocks,
+// chats, connections and interests rather than by the number of users.
+func discoveryStages(ctx context.Context, viewer primitive.ObjectID, now time.Time) (bson.A, error) {
+	blocked, err := blockedWith(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+	chatted, err := usersChattedWith(ctx, viewer, nil)
+	if err != nil {
+		return nil, err
+	}
+	connected, err := usersConnectedWith(ctx, viewer, nil)
+	if err != nil {
+		return nil, err
+	}
+	interests := []interface{}{}
+	if !viewer.IsZero() {
+		if interests, err = database.DB.Collection("user_interests").Distinct(ctx, "interest_id", bson.M{"user_id": viewer}); err != nil {
+			return nil, err
+		}
+	}
+	return visibilityStages(viewer, blocked, idSet(chatted), idSet(connected), interests, now), nil
+}
+
+// visibilityStages builds the stages of discoveryStages from what viewer is related to
+func visibilityStages(viewer primitive.ObjectID, blocked, chatted, connected []primitive.ObjectID, interests []interface{}, now time.Time) bson.A {
+	stages := bson.A{
+		bson.M{"$match": bson.M{"_id": bson.M{"$nin": blocked}, "$nor": bson.A{restrictedAccountsFilter(now)}}},
+		bson.M{"$lookup": bson.M{"from": "user_settings", "localField": "_id", "foreignField": "_id", "as": "discovery_settings"}},
+	}
+	visible := bson.A{
+		bson.M{"_id": viewer}, // people always see themselves
+		bson.M{"discovery_settings.discovery.visibility": bson.M{"$nin": restrictedVisibilities}},
+		bson.M{"discovery_settings.discovery.visibility": models.VisibilityChatted, "_id": bson.M{"$in": chatted}},
+		bson.M{"discovery_settings.discovery.visibility": models.VisibilityConnections, "_id": bson.M{"$in": connected}},
+	}
+	if len(interests) > 0 {
+		stages = append(stages, bson.M{"$lookup": bson.M{
+			"from": "user_interests",
+			"let":  bson.M{"user_id": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"interest_id": bson.M{"$in": interests}, "$expr": bson.M{"$eq": bson.A{"$user_id", "$$user_id"}}}},
+				bson.M{"$limit": 1},
+			},
+			"as": "shared_interests",
+		}})
+		visible = append(visible, bson.M{
+			"discovery_settings.discovery.visibility": models.VisibilitySharedInterests,
+			"shared_interests.0":                      bson.M{"$exists": true},
+		})
+	}
+	return append(stages,
+		bson.M{"$match": bson.M{"$or": visible}},
+		bson.M{"$project": bson.M{"discovery_settings": 0, "shared_interests": 0}},
+	)
+}
+
+// idSet lists the ids of a set
+func idSet(set map[primitive.ObjectID]bool) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// discoverableBy returns which of candidates viewer may discover, see hiddenFrom
+func discoverableBy(ctx context.Context, viewer primitive.ObjectID, candidates []primitive.ObjectID, nearby bool) (map[primitive.ObjectID]bool, error) {
+	allowed := make(map[primitive.ObjectID]bool, len(candidates))
+	if len(candidates) == 0 {
+		return allowed, nil
+	}
+	hidden, err := hiddenFrom(ctx, viewer, candidates, nearby)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range candidates {
+		allowed[id] = true
+	}
+	for _, id := range hidden {
+		delete(allowed, id)
+	}
+	return allowed, nil
+}
+
+// canMessage applies blocks and recipient's "who can message me" setting to sender
+func canMessage(ctx context.Context, sender primitive.ObjectID, recipient primitive.ObjectID) (bool, error) {
+	if sender == recipient {
+		return true, nil
+	}
+	if blocked, err := isBlocked(ctx, sender, recipient); err != nil || blocked {
+		return false, err
+	}
+	settings, err := loadUserSettings(ctx, recipient)
+	if err != nil {
+		return false, err
+	}
+	switch settings.Chat.WhoCanMessage {
+	case models.MessageNobody:
+		return false, nil
+	case models.MessageSharedInterests:
+		shared, err := usersSharingInterests(ctx, recipient, []primitive.ObjectID{sender})
+		return shared[sender], err
+	case models.MessageConnections:
+		return isConnected(ctx, sender, recipient)
+	default:
+		return true, nil
+	}
+}
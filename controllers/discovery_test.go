@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func settingsWith(id primitive.ObjectID, visibility string, visibleInNearby bool) models.UserSettings {
+	s := defaultUserSettings(id)
+	s.Discovery.Visibility = visibility
+	s.Discovery.VisibleInNearby = visibleInNearby
+	return s
+}
+
+func TestHiddenBySettings(t *testing.T) {
+	viewer := primitive.NewObjectID()
+	everyone, hidden, shared, chatted, connections, notNearby :=
+		primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(),
+		primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	settings := []models.UserSettings{
+		settingsWith(everyone, models.VisibilityEveryone, true),
+		settingsWith(hidden, models.VisibilityHidden, true),
+		settingsWith(shared, models.VisibilitySharedInterests, true),
+		settingsWith(chatted, models.VisibilityChatted, true),
+		settingsWith(connections, models.VisibilityConnections, true),
+		settingsWith(notNearby, models.VisibilityEveryone, false),
+		settingsWith(viewer, models.VisibilityHidden, false),
+	}
+
+	v := hiddenBySettings(settings, viewer, false)
+	if !sameIDs(v.hidden, hidden) {
+		t.Errorf("hidden = %v, want only the hidden user", v.hidden)
+	}
+	if !sameIDs(v.needShared, shared) || !sameIDs(v.needChatted, chatted) || !sameIDs(v.needConnected, connections) {
+		t.Errorf("got %+v, want each restricted user under its condition", v)
+	}
+
+	v = hiddenBySettings(settings, viewer, true)
+	if !sameIDs(v.hidden, hidden, notNearby) {
+		t.Errorf("nearby hidden = %v, want the hidden user and the one not visible nearby", v.hidden)
+	}
+}
+
+func TestVisibilityStages(t *testing.T) {
+	viewer, blocked, chatted, connected := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now()
+
+	stages := visibilityStages(viewer, []primitive.ObjectID{blocked}, []primitive.ObjectID{chatted}, []primitive.ObjectID{connected}, []interface{}{primitive.NewObjectID()}, now)
+	first := stages[0].(bson.M)["$match"].(bson.M)
+	if !sameIDs(first["_id"].(bson.M)["$nin"].([]primitive.ObjectID), blocked) {
+		t.Errorf("blocked users are not excluded: %v", first)
+	}
+	if _, ok := first["$nor"]; !ok {
+		t.Errorf("suspended and banned users are not excluded: %v", first)
+	}
+
+	visible := visibleClauses(t, stages)
+	want := map[string]bool{}
+	for _, clause := range visible {
+		switch clause["discovery_settings.discovery.visibility"] {
+		case models.VisibilityChatted:
+			want["chatted"] = sameIDs(clause["_id"].(bson.M)["$in"].([]primitive.ObjectID), chatted)
+		case models.VisibilityConnections:
+			want["connections"] = sameIDs(clause["_id"].(bson.M)["$in"].([]primitive.ObjectID), connected)
+		case models.VisibilitySharedInterests:
+			want["shared"] = clause["shared_interests.0"] != nil
+		case nil:
+			if id, ok := clause["_id"]; ok {
+				want["self"] = id == viewer
+			}
+		}
+	}
+	for _, key := range []string{"chatted", "connections", "shared", "self"} {
+		if !want[key] {
+			t.Errorf("missing or wrong %s clause in %v", key, visible)
+		}
+	}
+
+	// a viewer without interests shares none, so nobody restricted to shared interests is visible
+	stages = visibilityStages(primitive.ObjectID{}, nil, nil, nil, nil, now)
+	for _, clause := range visibleClauses(t, stages) {
+		if clause["discovery_settings.discovery.visibility"] == models.VisibilitySharedInterests {
+			t.Errorf("anonymous viewers see users restricted to shared interests: %v", clause)
+		}
+	}
+	for _, stage := range stages {
+		if lookup, ok := stage.(bson.M)["$lookup"]; ok && lookup.(bson.M)["from"] == "user_interests" {
+			t.Errorf("interests are looked up for a viewer without interests")
+		}
+	}
+}
+
+// visibleClauses returns the $or of the stage that applies visibility settings
+func visibleClauses(t *testing.T, stages bson.A) []bson.M {
+	t.Helper()
+	for _, stage := range stages {
+		if match, ok := stage.(bson.M)["$match"].(bson.M); ok {
+			if or, ok := match["$or"].(bson.A); ok {
+				clauses := make([]bson.M, len(or))
+				for i, c := range or {
+					clauses[i] = c.(bson.M)
+				}
+				return clauses
+			}
+		}
+	}
+	t.Fatalf("no visibility $match in %v", stages)
+	return nil
+}
+
+func sameIDs(got []primitive.ObjectID, want ...primitive.ObjectID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, id := range want {
+		if !containsObjectID(got, id) {
+			return false
+		}
+	}
+	return true
+}
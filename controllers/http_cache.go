@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"fast-af/config"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// notModified sets the ETag response header and reports whether the client's
+// If-None-Match already lists it, in which case the handler should answer 304.
+func notModified(c *fiber.Ctx, etag string) bool {
+	c.Set(fiber.HeaderETag, etag)
+	return utils.ETagMatches(c.Get(fiber.HeaderIfNoneMatch), etag)
+}
+
+// userETag changes whenever the stored user document is updated, and when the
+// computed age or the signed photo URLs embedded in the response roll over
+func userETag(user models.User) string {
+	parts := []string{"user", user.ID.Hex(), strconv.FormatInt(user.Version, 10), strconv.FormatInt(user.UpdatedAt.UnixNano(), 10)}
+	if user.DateOfBirth != nil {
+		// the computed age changes on birthdays
+		parts = append(parts, strconv.Itoa(utils.AgeAt(*user.DateOfBirth, time.Now())))
+	}
+	if len(user.Photos) > 0 {
+		parts = append(parts, strconv.FormatInt(utils.MediaURLExpiry(time.Now(), config.MediaURLTTL).Unix(), 10))
+	}
+	return utils.StrongETag(parts...)
+}
+
+func chatWindowETag(window models.ChatWindow) string {
+	return utils.StrongETag("chat_window", window.ID.Hex(), strconv.FormatInt(window.Version, 10))
+}
+
+func meetingRequestETag(req models.MeetingRequest) string {
+	return utils.StrongETag("meeting_request", req.ID.Hex(), strconv.FormatInt(req.Version, 10))
+}
+
+// Optimistic concurrency: versioned documents are only updated with a filter on the
+// version that was read, and every update increments it.
+
+// versionFilter matches documents at version, treating documents written before
+// versioning was introduced (no version field) as version 0
+func versionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// preconditionHolds checks the client's If-Match header against the current ETag and,
+// when given, the "version" the client based its edit on against the current version.
+// Requests carrying neither are treated as based on the current document.
+func preconditionHolds(c *fiber.Ctx, currentETag string, currentVersion int64, clientVersion *int64) bool {
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" && !utils.ETagMatchesStrong(ifMatch, currentETag) {
+		return false
+	}
+	if clientVersion != nil && *clientVersion != currentVersion {
+		return false
+	}
+	return true
+}
+
+// preconditionFailed answers 412 with the current document so the client can merge and retry
+func preconditionFailed(c *fiber.Ctx, etag string, current interface{}) error {
+	c.Set(fiber.HeaderETag, etag)
+	return c.Status(412).JSON(fiber.Map{
+		"error":   "Document was modified by someone else; merge with current and retry",
+		"current": current,
+	})
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// partialInterestMatch is what a related interest (same category node, or a parent or
+// child node) counts for in matching, against 1 for the same interest
+const partialInterestMatch = 0.5
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// categoryNode is a category with its subtree, as served by GET /interest-categories
+type categoryNode struct {
+	models.InterestCategory
+	InterestCount int             `json:"interestCount"` // interests in this node and below
+	Children      []*categoryNode `json:"children"`
+}
+
+// loadCategories returns every category, siblings in display order
+func loadCategories(ctx context.Context) ([]models.InterestCategory, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := database.DB.Collection("interest_categories").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	categories := []models.InterestCategory{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// categorySubtree returns the ids of the category and all categories below it
+func categorySubtree(ctx context.Context, categoryID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{categoryID}
+	below, err := database.DB.Collection("interest_categories").Distinct(ctx, "_id", bson.M{"path": categoryID})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range below {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			ids = append(ids, oid)
+		}
+	}
+	return ids, nil
+}
+
+// GET /interest-categories - the category tree with interest counts
+func GetInterestCategories(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	categories, err := loadCategories(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interest categories"})
+	}
+	var counts []struct {
+		CategoryID primitive.ObjectID `bson:"_id"`
+		N          int                `bson:"n"`
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"category_id": bson.M{"$exists": true}}},
+		bson.M{"$group": bson.M{"_id": "$category_id", "n": bson.M{"$sum": 1}}},
+	}
+	if err := aggregateAll(ctx, "interests", pipeline, &counts); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error counting interests"})
+	}
+
+	nodes := make(map[primitive.ObjectID]*categoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &categoryNode{InterestCategory: category, Children: []*categoryNode{}}
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.CategoryID]; ok {
+			node.InterestCount += count.N
+			// path lists every ancestor, so each one counts the interest once
+			for _, ancestor := range node.Path {
+				if a, ok := nodes[ancestor]; ok {
+					a.InterestCount += count.N
+				}
+			}
+		}
+	}
+	roots := []*categoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	encoded, _ := json.Marshal(roots)
+	if notModified(c, utils.StrongETag("interest_categories", string(encoded))) {
+		return c.SendStatus(304)
+	}
+	return c.JSON(roots)
+}
+
+// GET /interest-categories/:slug/interests - interests in the category and every category below it
+func GetCategoryInterests(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	var category models.InterestCategory
+	err := database.DB.Collection("interest_categories").FindOne(ctx, bson.M{"slug": c.Params("slug")}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(404).JSON(fiber.Map{"error": "Interest category not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interest category"})
+	}
+	ids, err := categorySubtree(ctx, category.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interest categories"})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := database.DB.Collection("interests").Find(ctx, bson.M{"category_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interests"})
+	}
+	interests := []models.Interest{}
+	if err := cursor.All(ctx, &interests); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error decoding interests"})
+	}
+
+	encoded, _ := json.Marshal(interests)
+	if notModified(c, utils.StrongETag("interest_categories", category.Slug, string(encoded))) {
+		return c.SendStatus(304)
+	}
+	return c.JSON(fiber.Map{"category": category, "interests": interests})
+}
+
+// POST /interest-categories {"name": "...", "slug": "...", "parentId": "<hex>", "order": 0} - admins only.
+// The slug defaults to one derived from the name.
+func CreateInterestCategory(c *fiber.Ctx) error {
+	var req struct {
+		Name     string              `json:"name"`
+		Slug     string              `json:"slug"`
+		ParentID *primitive.ObjectID `json:"parentId"`
+		Order    int                 `json:"order"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Category name cannot be empty"})
+	}
+	if req.Slug == "" {
+		req.Slug = utils.Slugify(req.Name)
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		return c.Status(400).JSON(fiber.Map{"error": "slug must be lowercase letters and digits separated by dashes"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	category := models.InterestCategory{Name: req.Name, Slug: req.Slug, ParentID: req.ParentID, Path: []primitive.ObjectID{}, Order: req.Order}
+	if req.ParentID != nil {
+		var parent models.InterestCategory
+		err := database.DB.Collection("interest_categories").FindOne(ctx, bson.M{"_id": *req.ParentID}).Decode(&parent)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "Parent category not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error fetching parent category"})
+		}
+		category.Path = append(parent.Path, parent.ID)
+	}
+	res, err := database.DB.Collection("interest_categories").InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(409).JSON(fiber.Map{"error": "A category with this slug already exists"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error creating interest category"})
+	}
+	category.ID = res.InsertedID.(primitive.ObjectID)
+	return c.Status(201).JSON(category)
+}
+
+// relatedInterestWeights maps each of interestIDs to 1 and every interest related to one of
+// them (in the same category node, or in its parent or a child node) to partialInterestMatch
+func relatedInterestWeights(ctx context.Context, interestIDs []primitive.ObjectID) (map[primitive.ObjectID]float64, error) {
+	weights := make(map[primitive.ObjectID]float64, len(interestIDs))
+	for _, id := range interestIDs {
+		weights[id] = 1
+	}
+	categoryIDs, err := database.DB.Collection("interests").Distinct(ctx, "category_id", bson.M{
+		"_id":         bson.M{"$in": interestIDs},
+		"category_id": bson.M{"$exists": true},
+	})
+	if err != nil || len(categoryIDs) == 0 {
+		return weights, err
+	}
+	cursor, err := database.DB.Collection("interest_categories").Find(ctx, bson.M{"_id": bson.M{"$in": categoryIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.InterestCategory
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	related := bson.A{}
+	for _, category := range categories {
+		related = append(related, category.ID)
+		if category.ParentID != nil {
+			related = append(related, *category.ParentID)
+		}
+	}
+	children, err := database.DB.Collection("interest_categories").Distinct(ctx, "_id", bson.M{"parent_id": bson.M{"$in": categoryIDs}})
+	if err != nil {
+		return nil, err
+	}
+	related = append(related, children...)
+
+	relatedInterests, err := database.DB.Collection("interests").Distinct(ctx, "_id", bson.M{"category_id": bson.M{"$in": related}})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range relatedInterests {
+		if oid, ok := id.(primitive.ObjectID); ok && weights[oid] == 0 {
+			weights[oid] = partialInterestMatch
+		}
+	}
+	return weights, nil
+}
+
+// sortByInterestMatch orders users by their interest match score, best first
+func sortByInterestMatch(users []models.User) {
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].InterestMatch.Score > users[j].InterestMatch.Score
+	})
+}
@@ -2,34 +2,94 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"fast-af/config"
 	"fast-af/database"
 	"fast-af/models"
+	"fast-af/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func GetAllInterests(c *fiber.Ctx) error {
-	var interests []models.Interest
-	cursor, err := database.DB.Collection("interests").Find(nil, bson.M{})
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interests"})
+// cachedInterests is a catalog response kept in interestCatalogCache
+type cachedInterests struct {
+	interests []models.Interest
+	etag      string
+}
+
+// interestCatalogTTL bounds how long catalog edits made by another process (such as the
+// admin CLI) can go unnoticed
+const interestCatalogTTL = time.Minute
+
+// interestCatalogCache holds catalog reads keyed by "all" or "search:<pattern>".
+// It is purged whenever this process changes the catalog so readers never see removed
+// interests, and entries expire after interestCatalogTTL.
+var interestCatalogCache = utils.NewLRU[string, cachedInterests](128, interestCatalogTTL)
+
+// interestCatalogGeneration counts catalog writes, so a load that raced with one is not cached
+var interestCatalogGeneration atomic.Uint64
+
+// purgeInterestCatalog drops every cached catalog read after a write
+func purgeInterestCatalog() {
+	interestCatalogGeneration.Add(1)
+	interestCatalogCache.Purge()
+}
+
+// serveInterestCatalog answers from the cache, loading through load on a miss
+func serveInterestCatalog(c *fiber.Ctx, key string, load func() ([]models.Interest, error)) error {
+	entry, ok := interestCatalogCache.Get(key)
+	if !ok {
+		generation := interestCatalogGeneration.Load()
+		interests, err := load()
+		if err != nil {
+			return err
+		}
+		encoded, _ := json.Marshal(interests)
+		entry = cachedInterests{interests: interests, etag: utils.StrongETag("interests", string(encoded))}
+		// a write during the load may have removed what was read: serve it once, don't keep it.
+		// Checking after Add leaves no gap, since a write after the check purges the entry.
+		interestCatalogCache.Add(key, entry)
+		if interestCatalogGeneration.Load() != generation {
+			interestCatalogCache.Remove(key)
+		}
 	}
-	defer cursor.Close(nil)
 
-	for cursor.Next(nil) {
-		var interest models.Interest
-		cursor.Decode(&interest)
-		interests = append(interests, interest)
+	if notModified(c, entry.etag) {
+		return c.SendStatus(304)
 	}
+	return c.JSON(entry.interests)
+}
+
+func GetAllInterests(c *fiber.Ctx) error {
+	err := serveInterestCatalog(c, "all", func() ([]models.Interest, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		defer cancel()
+
+		var interests []models.Interest
+		cursor, err := database.DB.Collection("interests").Find(ctx, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
 
-	return c.JSON(interests)
+		for cursor.Next(ctx) {
+			var interest models.Interest
+			cursor.Decode(&interest)
+			interests = append(interests, interest)
+		}
+		return interests, nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching interests"})
+	}
+	return nil
 }
 
 func CreateInterest(c *fiber.Ctx) error {
@@ -51,23 +111,41 @@ func CreateInterest(c *fiber.Ctx) error {
 	if count > 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "Interest with this name already exists"})
 	}
+	// interests filed in the category tree carry their node's name as the category
+	if interest.CategoryID != nil {
+		var category models.InterestCategory
+		err := database.DB.Collection("interest_categories").FindOne(ctx, bson.M{"_id": *interest.CategoryID}).Decode(&category)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(400).JSON(fiber.Map{"error": "Interest category not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error checking interest category"})
+		}
+		interest.Category = category.Name
+	}
 
-	_, err = database.DB.Collection("interests").InsertOne(nil, interest)
+	res, err := database.DB.Collection("interests").InsertOne(ctx, interest)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error creating interest"})
 	}
+	interest.ID = res.InsertedID.(primitive.ObjectID)
+	purgeInterestCatalog()
 
 	return c.Status(201).JSON(interest)
 }
 
 func RemoveInterest(c *fiber.Ctx) error {
-	interestID := c.Params("id")
+	interestID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid interest ID"})
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
-	_, err := database.DB.Collection("interests").DeleteOne(ctx, bson.M{"_id": interestID})
+	_, err = database.DB.Collection("interests").DeleteOne(ctx, bson.M{"_id": interestID})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error deleting interest"})
 	}
+	purgeInterestCatalog()
 
 	return c.Status(200).JSON(fiber.Map{"message": "Interest deleted"})
 }
@@ -163,14 +241,18 @@ func GetUserInterests(c *fiber.Ctx) error {
 func RemoveUserInterest(c *fiber.Ctx) error {
 	userID := c.Params("userId")
 	interestID := c.Params("interestId")
-	uid, err := uuid.Parse(userID)
+	uid, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
+	interestObjectID, err := primitive.ObjectIDFromHex(interestID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid interest ID"})
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
-	_, err = database.DB.Collection("user_interests").DeleteOne(ctx, bson.M{"user_id": uid, "interest_id": interestID})
+	_, err = database.DB.Collection("user_interests").DeleteOne(ctx, bson.M{"user_id": uid, "interest_id": interestObjectID})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error removing user interest"})
 	}
@@ -195,12 +277,14 @@ func SearchInterestByPattern(pattern string) ([]models.Interest, error) {
 	return interests, nil
 }
 
+// GET /interests/matches/:pattern (v1) or /interests/search?q=<pattern> (v2)
 func SearchInterests(c *fiber.Ctx) error {
-	pattern := c.Params("pattern", "")
-	interests, err := SearchInterestByPattern(pattern)
+	pattern := c.Params("pattern", c.Query("q"))
+	err := serveInterestCatalog(c, "search:"+pattern, func() ([]models.Interest, error) {
+		return SearchInterestByPattern(pattern)
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error searching interests"})
 	}
-
-	return c.JSON(interests)
+	return nil
 }
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serveCatalog runs serveInterestCatalog for key with load through a throwaway app
+func serveCatalog(t *testing.T, key string, load func() ([]models.Interest, error)) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error { return serveInterestCatalog(c, key, load) })
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+}
+
+func TestPurgeInterestCatalog(t *testing.T) {
+	loads := 0
+	load := func() ([]models.Interest, error) {
+		loads++
+		return []models.Interest{{Name: "climbing"}}, nil
+	}
+	serveCatalog(t, "all", load)
+	serveCatalog(t, "all", load)
+	if loads != 1 {
+		t.Fatalf("catalog loaded %d times before a write, want 1", loads)
+	}
+
+	generation := interestCatalogGeneration.Load()
+	purgeInterestCatalog()
+	if interestCatalogGeneration.Load() != generation+1 {
+		t.Error("purge did not advance the catalog generation")
+	}
+	if _, ok := interestCatalogCache.Get("all"); ok {
+		t.Error("purge left a cached catalog read")
+	}
+	serveCatalog(t, "all", load)
+	if loads != 2 {
+		t.Errorf("catalog loaded %d times after a write, want 2", loads)
+	}
+}
+
+func TestInterestCatalogReadRacingAWriteIsNotCached(t *testing.T) {
+	purgeInterestCatalog()
+	serveCatalog(t, "search:clim", func() ([]models.Interest, error) {
+		purgeInterestCatalog() // a write lands while the catalog is being read
+		return []models.Interest{{Name: "climbing"}}, nil
+	})
+	if _, ok := interestCatalogCache.Get("search:clim"); ok {
+		t.Error("a read that raced a write was cached")
+	}
+}
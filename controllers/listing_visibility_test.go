@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The tests in this file run the listing handlers against a real MongoDB, which the
+// visibility rules are largely evaluated by. Set MONGODB_TEST_URI (for instance
+// mongodb://localhost:27017) to run them; each test gets its own database, dropped afterwards.
+
+// useTestDatabase points database.DB at a fresh database, or skips the test without one
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, timeout, minimumAge, awayAfter := database.DB, config.DefaultDBContextTimeout, config.MinimumAge, config.PresenceAwayAfter
+	database.DB = client.Database("fast_af_test_" + primitive.NewObjectID().Hex())
+	config.DefaultDBContextTimeout, config.MinimumAge, config.PresenceAwayAfter = 10, 18, 5*time.Minute
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		database.DB.Drop(ctx)
+		client.Disconnect(ctx)
+		database.DB, config.DefaultDBContextTimeout, config.MinimumAge, config.PresenceAwayAfter = previous, timeout, minimumAge, awayAfter
+	})
+}
+
+// listingWorld is a viewer and the people around them. Everyone is an adult standing at
+// the same spot, in an active proximity session, sharing an interest with everyone else;
+// only visible should ever be listed to viewer.
+type listingWorld struct {
+	viewer, visible                 primitive.ObjectID
+	hidden, suspended, banned       primitive.ObjectID
+	blockedByViewer, blockingViewer primitive.ObjectID
+	interest                        primitive.ObjectID
+}
+
+func newListingWorld(t *testing.T) listingWorld {
+	t.Helper()
+	w := listingWorld{
+		viewer: primitive.NewObjectID(), visible: primitive.NewObjectID(),
+		hidden: primitive.NewObjectID(), suspended: primitive.NewObjectID(), banned: primitive.NewObjectID(),
+		blockedByViewer: primitive.NewObjectID(), blockingViewer: primitive.NewObjectID(),
+		interest: primitive.NewObjectID(),
+	}
+	now := time.Now()
+	dob := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	suspendedUntil := now.Add(24 * time.Hour)
+
+	people := map[primitive.ObjectID]*models.User{
+		w.viewer:          {Name: "viewer"},
+		w.visible:         {Name: "visible"},
+		w.hidden:          {Name: "hidden"},
+		w.suspended:       {Name: "suspended", Status: models.UserStatusSuspended, SuspendedUntil: &suspendedUntil},
+		w.banned:          {Name: "banned", Status: models.UserStatusBanned},
+		w.blockedByViewer: {Name: "blocked by viewer"},
+		w.blockingViewer:  {Name: "blocking viewer"},
+	}
+	for id, user := range people {
+		user.ID, user.DateOfBirth, user.Locality, user.CreatedAt = id, &dob, "Indiranagar, Bengaluru", now
+		insert(t, "users", user)
+		insert(t, "user_interests", models.UserInterest{UserID: id, InterestID: w.interest})
+		insert(t, "active_proximities", models.ActiveProximity{
+			UserID: id, Latitude: 12.9716, Longitude: 77.5946, Radius: 5000, CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+		})
+	}
+	hidden := defaultUserSettings(w.hidden)
+	hidden.Discovery.Visibility = models.VisibilityHidden
+	insert(t, "user_settings", hidden)
+	insert(t, "user_blocks", models.UserBlock{BlockerID: w.viewer, BlockedID: w.blockedByViewer, CreatedAt: now})
+	insert(t, "user_blocks", models.UserBlock{BlockerID: w.blockingViewer, BlockedID: w.viewer, CreatedAt: now})
+	insert(t, "interests", models.Interest{ID: w.interest, Name: "climbing"})
+	return w
+}
+
+func insert(t *testing.T, collection string, doc interface{}) {
+	t.Helper()
+	if _, err := database.DB.Collection(collection).InsertOne(context.Background(), doc); err != nil {
+		t.Fatalf("inserting into %s: %v", collection, err)
+	}
+}
+
+// listAs calls handler at path as the authenticated caller and returns the ids it listed.
+// Entries are users ("id"), proximities ("userId") or suggestions ("user.id"), either
+// as a bare array or under "results".
+func listAs(t *testing.T, caller primitive.ObjectID, route string, path string, handler fiber.Handler) []string {
+	t.Helper()
+	app := fiber.New()
+	app.Get(route, func(c *fiber.Ctx) error {
+		c.Locals(middleware.CurrentUserKey, caller)
+		return c.Next()
+	}, handler)
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil), 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("GET %s: status %d: %s", path, resp.StatusCode, body)
+	}
+	var paged struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if json.Unmarshal(body, &paged) == nil && paged.Results != nil {
+		body = paged.Results
+	}
+	var entries []struct {
+		ID     string `json:"id"`
+		UserID string `json:"userId"`
+		User   struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		t.Fatalf("GET %s: %v: %s", path, err, body)
+	}
+	ids := []string{}
+	for _, e := range entries {
+		switch {
+		case e.UserID != "":
+			ids = append(ids, e.UserID)
+		case e.User.ID != "":
+			ids = append(ids, e.User.ID)
+		default:
+			ids = append(ids, e.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func expectListed(t *testing.T, listing string, got []string, want ...primitive.ObjectID) {
+	t.Helper()
+	hexes := make([]string, len(want))
+	for i, id := range want {
+		hexes[i] = id.Hex()
+	}
+	sort.Strings(hexes)
+	if len(got) != len(hexes) {
+		t.Errorf("%s listed %v, want %v", listing, got, hexes)
+		return
+	}
+	for i := range got {
+		if got[i] != hexes[i] {
+			t.Errorf("%s listed %v, want %v", listing, got, hexes)
+			return
+		}
+	}
+}
+
+func TestListingsLeaveOutHiddenSuspendedAndBlockedUsers(t *testing.T) {
+	useTestDatabase(t)
+	w := newListingWorld(t)
+	viewer := w.viewer.Hex()
+
+	expectListed(t, "nearby",
+		listAs(t, w.viewer, "/nearby/:userId", "/nearby/"+viewer, GetNearbyUsers), w.visible)
+	// the viewer's own session is part of the active proximities
+	expectListed(t, "active proximities",
+		listAs(t, w.viewer, "/proximities", "/proximities", GetAllActiveProximities), w.viewer, w.visible)
+	expectListed(t, "interest matches",
+		listAs(t, w.viewer, "/matches/:userId", "/matches/"+viewer, GetUsersByInterests), w.visible)
+	expectListed(t, "matches by interest",
+		listAs(t, w.viewer, "/matches", "/matches?interestIds="+w.interest.Hex(), GetUsersByInterests), w.viewer, w.visible)
+	expectListed(t, "search",
+		listAs(t, w.viewer, "/search", "/search", SearchUsers), w.visible)
+	expectListed(t, "search by interest",
+		listAs(t, w.viewer, "/search", "/search?interestIds="+w.interest.Hex(), SearchUsers), w.visible)
+	expectListed(t, "users",
+		listAs(t, w.viewer, "/users", "/users", GetUsers), w.viewer, w.visible)
+	expectListed(t, "suggestions",
+		listAs(t, w.viewer, "/suggestions/:userId", "/suggestions/"+viewer, GetSuggestions), w.visible)
+}
+
+func TestCachedSuggestionsLeaveOutUsersSuspendedSinceRanking(t *testing.T) {
+	useTestDatabase(t)
+	w := newListingWorld(t)
+	path := "/suggestions/" + w.viewer.Hex()
+
+	expectListed(t, "suggestions", listAs(t, w.viewer, "/suggestions/:userId", path, GetSuggestions), w.visible)
+	if err := SuspendUser(context.Background(), w.visible, time.Now().Add(time.Hour), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := suggestionsCache.Get(w.viewer); !ok {
+		t.Fatal("the ranking was not cached, so this test would not exercise the cached path")
+	}
+	expectListed(t, "suggestions after a suspension", listAs(t, w.viewer, "/suggestions/:userId", path, GetSuggestions))
+}
+
+func TestListingsLeaveOutUsersWhoTurnedOffNearbyVisibility(t *testing.T) {
+	useTestDatabase(t)
+	w := newListingWorld(t)
+	settings := defaultUserSettings(w.visible)
+	settings.Discovery.VisibleInNearby = false
+	insert(t, "user_settings", settings)
+
+	expectListed(t, "nearby",
+		listAs(t, w.viewer, "/nearby/:userId", "/nearby/"+w.viewer.Hex(), GetNearbyUsers))
+	expectListed(t, "active proximities",
+		listAs(t, w.viewer, "/proximities", "/proximities", GetAllActiveProximities), w.viewer)
+	// outside nearby listings they stay discoverable
+	expectListed(t, "search",
+		listAs(t, w.viewer, "/search", "/search", SearchUsers), w.visible)
+}
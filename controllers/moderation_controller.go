@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// openReportStatuses are the statuses a moderator can still act on
+var openReportStatuses = bson.A{models.ReportOpen, models.ReportInReview}
+
+// recordModeration appends an entry to the moderation audit trail
+func recordModeration(ctx context.Context, reportID primitive.ObjectID, moderator primitive.ObjectID, action string, subject *primitive.ObjectID, note string) error {
+	_, err := database.DB.Collection("moderation_events").InsertOne(ctx, models.ModerationEvent{
+		ReportID:    reportID,
+		ModeratorID: moderator,
+		Action:      action,
+		SubjectID:   subject,
+		Note:        note,
+		CreatedAt:   time.Now(),
+	})
+	return err
+}
+
+// loadReport reads the :reportId report, sending the error response itself when it fails
+func loadReport(ctx context.Context, c *fiber.Ctx) (*models.Report, error) {
+	reportID, err := primitive.ObjectIDFromHex(c.Params("reportId"))
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid report ID"})
+	}
+	var report models.Report
+	if err := database.DB.Collection("reports").FindOne(ctx, bson.M{"_id": reportID}).Decode(&report); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, c.Status(404).JSON(fiber.Map{"error": "Report not found"})
+		}
+		return nil, c.Status(500).JSON(fiber.Map{"error": "Failed to fetch report"})
+	}
+	return &report, nil
+}
+
+// GET /moderation/reports?status=open,in_review&assignee=me|none|<hex>&reason=&reportedUserId=&page=1&limit=20
+// The queue is oldest first so nothing waits indefinitely.
+func GetModerationQueue(c *fiber.Ctx) error {
+	caller, _ := middleware.CurrentUserID(c)
+	filter := bson.M{}
+
+	statuses := bson.A{}
+	for _, s := range strings.Split(c.Query("status", models.ReportOpen+","+models.ReportInReview), ",") {
+		switch s = strings.TrimSpace(s); s {
+		case models.ReportOpen, models.ReportInReview, models.ReportResolved, models.ReportDismissed:
+			statuses = append(statuses, s)
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid status: " + s})
+		}
+	}
+	filter["status"] = bson.M{"$in": statuses}
+
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "me":
+		filter["assignee_id"] = caller
+	case "none":
+		filter["assignee_id"] = nil
+	default:
+		oid, err := primitive.ObjectIDFromHex(assignee)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "assignee must be me, none or a user ID"})
+		}
+		filter["assignee_id"] = oid
+	}
+	if reason := c.Query("reason"); reason != "" {
+		if !validReportReason(reason) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid reason"})
+		}
+		filter["reason"] = reason
+	}
+	if v := c.Query("reportedUserId"); v != "" {
+		oid, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid reportedUserId"})
+		}
+		filter["reported_user_id"] = oid
+	}
+	page, limit, err := pageParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	total, err := database.DB.Collection("reports").CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports"})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cursor, err := database.DB.Collection("reports").Find(ctx, filter, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports"})
+	}
+	reports := []models.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode reports"})
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"results": reports,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// GET /moderation/reports/:reportId - the report with the reported user's profile and history
+func GetModerationReport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	report, err := loadReport(ctx, c)
+	if report == nil {
+		return err
+	}
+	// moderators see the profile as its owner does, including pending photos and date of birth
+	user, err := FindUser(ctx, report.ReportedUserID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reported user"})
+	}
+	if user != nil {
+		presentUser(user, user.ID)
+	}
+	reports, err := database.DB.Collection("reports").CountDocuments(ctx, bson.M{"reported_user_id": report.ReportedUserID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count reports"})
+	}
+	warnings, err := database.DB.Collection("user_warnings").CountDocuments(ctx, bson.M{"user_id": report.ReportedUserID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count warnings"})
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"report":       report,
+		"reportedUser": user,
+		"history":      fiber.Map{"reports": reports, "warnings": warnings},
+	})
+}
+
+// POST /moderation/reports/:reportId/assign {"moderatorId": "<hex>"} - assign an open report,
+// to the caller when moderatorId is omitted. Assigned reports move to in_review.
+func AssignReport(c *fiber.Ctx) error {
+	caller, _ := middleware.CurrentUserID(c)
+	var req struct {
+		ModeratorID string `json:"moderatorId"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+	}
+	assignee := caller
+	if req.ModeratorID != "" {
+		oid, err := primitive.ObjectIDFromHex(req.ModeratorID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid moderatorId"})
+		}
+		assignee = oid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	report, err := loadReport(ctx, c)
+	if report == nil {
+		return err
+	}
+	if assignee != caller {
+		moderator, err := FindUser(ctx, assignee)
+		if err != nil || (moderator.Role != models.RoleModerator && moderator.Role != models.RoleAdmin) {
+			return c.Status(400).JSON(fiber.Map{"error": "moderatorId must be a moderator"})
+		}
+	}
+	if assignee == report.ReportedUserID {
+		return c.Status(400).JSON(fiber.Map{"error": "Reports cannot be assigned to the reported user"})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = database.DB.Collection("reports").FindOneAndUpdate(ctx,
+		bson.M{"_id": report.ID, "status": bson.M{"$in": openReportStatuses}},
+		bson.M{
+			"$set": bson.M{"assignee_id": assignee, "status": models.ReportInReview, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		}, opts).Decode(report)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(fiber.Map{"error": "Report is already closed"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to assign report"})
+	}
+	if err := recordModeration(ctx, report.ID, caller, models.ModerationAssign, &assignee, ""); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Report assigned but the audit entry failed"})
+	}
+	return c.Status(200).JSON(report)
+}
+
+// POST /moderation/reports/:reportId/unassign - put the report back in the open queue
+func UnassignReport(c *fiber.Ctx) error {
+	caller, _ := middleware.CurrentUserID(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	report, err := loadReport(ctx, c)
+	if report == nil {
+		return err
+	}
+	previous := report.AssigneeID
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = database.DB.Collection("reports").FindOneAndUpdate(ctx,
+		bson.M{"_id": report.ID, "status": models.ReportInReview},
+		bson.M{
+			"$set":   bson.M{"status": models.ReportOpen, "updated_at": time.Now()},
+			"$unset": bson.M{"assignee_id": ""},
+			"$inc":   bson.M{"version": 1},
+		}, opts).Decode(report)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(fiber.Map{"error": "Report is not assigned"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to unassign report"})
+	}
+	if err := recordModeration(ctx, report.ID, caller, models.ModerationUnassign, previous, ""); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Report unassigned but the audit entry failed"})
+	}
+	return c.Status(200).JSON(report)
+}
+
+// POST /moderation/reports/:reportId/resolve {"action": "warn|suspend|ban|dismiss", "note": "...", "suspendFor": "72h"}
+// Closes the report and applies the action to the reported user.
+func ResolveReport(c *fiber.Ctx) error {
+	caller, _ := middleware.CurrentUserID(c)
+	var req struct {
+		Action     string `json:"action"`
+		Note       string `json:"note"`
+		SuspendFor string `json:"suspendFor"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	note := strings.TrimSpace(req.Note)
+	if len(note) > maxReportDetails {
+		return c.Status(400).JSON(fiber.Map{"error": "note must be at most 2000 characters"})
+	}
+
+	now := time.Now()
+	resolution := models.ReportResolution{Action: req.Action, Note: note, ModeratorID: caller, ResolvedAt: now}
+	status := models.ReportResolved
+	switch req.Action {
+	case models.ModerationWarn, models.ModerationBan:
+	case models.ModerationDismiss:
+		status = models.ReportDismissed
+	case models.ModerationSuspend:
+		d, err := time.ParseDuration(req.SuspendFor)
+		if err != nil || d <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "suspendFor must be a positive duration such as 72h"})
+		}
+		until := now.Add(d)
+		resolution.SuspendedUntil = &until
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "action must be warn, suspend, ban or dismiss"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	report, err := loadReport(ctx, c)
+	if report == nil {
+		return err
+	}
+	if report.ReportedUserID == caller {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot resolve a report against yourself"})
+	}
+
+	// closing the report first means two moderators can never both act on it
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = database.DB.Collection("reports").FindOneAndUpdate(ctx,
+		bson.M{"_id": report.ID, "status": bson.M{"$in": openReportStatuses}},
+		bson.M{
+			"$set": bson.M{"status": status, "resolution": resolution, "updated_at": now},
+			"$inc": bson.M{"version": 1},
+		}, opts).Decode(report)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(409).JSON(fiber.Map{"error": "Report is already closed"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to resolve report"})
+	}
+
+	reason := report.Reason
+	if note != "" {
+		reason = note
+	}
+	switch req.Action {
+	case models.ModerationWarn:
+		_, err = database.DB.Collection("user_warnings").InsertOne(ctx, models.UserWarning{
+			UserID:      report.ReportedUserID,
+			ReportID:    report.ID,
+			Reason:      report.Reason,
+			Note:        note,
+			ModeratorID: caller,
+			CreatedAt:   now,
+		})
+	case models.ModerationSuspend:
+		err = SuspendUser(ctx, report.ReportedUserID, *resolution.SuspendedUntil, reason)
+	case models.ModerationBan:
+		err = BanUser(ctx, report.ReportedUserID, reason)
+	}
+	if err != nil {
+		// reopen the report so the action can be retried
+		database.DB.Collection("reports").UpdateOne(ctx, bson.M{"_id": report.ID}, bson.M{
+			"$set":   bson.M{"status": models.ReportInReview, "assignee_id": caller, "updated_at": time.Now()},
+			"$unset": bson.M{"resolution": ""},
+			"$inc":   bson.M{"version": 1},
+		})
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to apply " + req.Action})
+	}
+
+	if err := recordModeration(ctx, report.ID, caller, req.Action, &report.ReportedUserID, note); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Report resolved but the audit entry failed"})
+	}
+	// moderation actions and no-show reports count toward the trust score
+	refreshTrustScoreLater(report.ReportedUserID)
+	return c.Status(200).JSON(report)
+}
+
+// GET /moderation/reports/:reportId/audit - every moderation action taken on the report
+func GetReportAudit(c *fiber.Ctx) error {
+	reportID, err := primitive.ObjectIDFromHex(c.Params("reportId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid report ID"})
+	}
+	return moderationEvents(c, bson.M{"report_id": reportID}, 0)
+}
+
+// GET /moderation/audit?moderatorId=&limit=100 - recent moderation actions, newest first
+func GetModerationAudit(c *fiber.Ctx) error {
+	filter := bson.M{}
+	if v := c.Query("moderatorId"); v != "" {
+		oid, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid moderatorId"})
+		}
+		filter["moderator_id"] = oid
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 500 {
+		return c.Status(400).JSON(fiber.Map{"error": "limit must be between 1 and 500"})
+	}
+	return moderationEvents(c, filter, int64(limit))
+}
+
+// moderationEvents lists audit entries, chronologically for one report (limit 0)
+// and newest first otherwise
+func moderationEvents(c *fiber.Ctx, filter bson.M, limit int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	if limit > 0 {
+		opts = options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	}
+	cursor, err := database.DB.Collection("moderation_events").Find(ctx, filter, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch audit trail"})
+	}
+	events := []models.ModerationEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode audit trail"})
+	}
+	return c.Status(200).JSON(events)
+}
@@ -0,0 +1,401 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+	"fast-af/storage"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	photoMaxSide       = 2048
+	photoThumbnailSide = 320
+	photoMaxPixels     = 40_000_000 // larger images are refused before decoding
+)
+
+// mediaURL returns a signed, expiring URL for a stored object
+func mediaURL(key string) string {
+	expires := utils.MediaURLExpiry(time.Now(), config.MediaURLTTL)
+	return config.PublicBaseURL + "/api/v2/media/" + (&url.URL{Path: key}).EscapedPath() + "?" +
+		utils.SignMediaQuery(key, expires, config.AuthTokenSecret)
+}
+
+// presentPhoto fills the signed URLs of a stored photo
+func presentPhoto(photo *models.StoredPhoto) {
+	photo.URL = mediaURL(photo.Key)
+	photo.ThumbnailURL = mediaURL(photo.ThumbnailKey)
+}
+
+// visiblePhotos returns the part of a gallery viewer may see, with signed URLs:
+// owners see every photo with its moderation status, everyone else only approved ones.
+func visiblePhotos(owner primitive.ObjectID, photos []models.Photo, viewer primitive.ObjectID) []models.Photo {
+	visible := []models.Photo{}
+	for _, photo := range photos {
+		if photo.ModerationStatus != models.PhotoApproved && viewer != owner {
+			continue
+		}
+		presentPhoto(&photo.StoredPhoto)
+		visible = append(visible, photo)
+	}
+	return visible
+}
+
+// presentUser prepares a user document for a response to viewer (zero when anonymous).
+// Uploaded photos are only reachable through signed URLs, which are generated here, and
+// the profile picture is the primary (first) photo of the gallery once approved. The age is computed
+// from the date of birth, which like the age preference and the account status is only
+// shown to its owner.
+func presentUser(user *models.User, viewer primitive.ObjectID) {
+	user.Age = userAge(user, time.Now())
+	if viewer != user.ID {
+		user.DateOfBirth = nil
+		user.AgePreference = nil
+		user.Status = ""
+		user.SuspendedUntil = nil
+		user.SuspensionReason = ""
+	}
+	primaryApproved := len(user.Photos) > 0 && user.Photos[0].ModerationStatus == models.PhotoApproved
+	user.Photos = visiblePhotos(user.ID, user.Photos, viewer)
+	if primaryApproved {
+		user.ProfilePictureURL = user.Photos[0].URL
+	}
+}
+
+func presentUsers(users []models.User, viewer primitive.ObjectID) {
+	for i := range users {
+		presentUser(&users[i], viewer)
+	}
+}
+
+// viewerID is the authenticated caller, or the zero ID on unauthenticated routes
+func viewerID(c *fiber.Ctx) primitive.ObjectID {
+	viewer, _ := middleware.CurrentUserID(c)
+	return viewer
+}
+
+// storePhoto validates, sanitises and stores an uploaded image with its thumbnail.
+// The returned error message is safe to show to clients; status is the HTTP status to use.
+func storePhoto(ctx context.Context, userID primitive.ObjectID, data []byte) (*models.StoredPhoto, int, string) {
+	processed, err := utils.ProcessUploadedImage(data, photoMaxSide, photoThumbnailSide, photoMaxPixels)
+	if err == utils.ErrUnsupportedImage {
+		return nil, 415, err.Error()
+	}
+	if err == utils.ErrImageTooLarge {
+		return nil, 413, err.Error()
+	}
+	if err != nil {
+		return nil, 500, "Failed to process image"
+	}
+
+	random := make([]byte, 12)
+	rand.Read(random)
+	base := "users/" + userID.Hex() + "/photos/" + hex.EncodeToString(random)
+	photo := &models.StoredPhoto{
+		Key:          base + ".jpg",
+		ThumbnailKey: base + "_thumb.jpg",
+		Width:        processed.Width,
+		Height:       processed.Height,
+		UploadedAt:   time.Now(),
+	}
+	if err := storage.Files.Put(ctx, photo.Key, processed.Data, "image/jpeg"); err != nil {
+		log.Println("storing photo:", err)
+		return nil, 500, "Failed to store photo"
+	}
+	if err := storage.Files.Put(ctx, photo.ThumbnailKey, processed.Thumbnail, "image/jpeg"); err != nil {
+		log.Println("storing thumbnail:", err)
+		storage.Files.Delete(ctx, photo.Key)
+		return nil, 500, "Failed to store photo"
+	}
+	return photo, 0, ""
+}
+
+// deletePhotoBlobs removes a photo's objects; failures only leave orphans, so they are logged
+func deletePhotoBlobs(ctx context.Context, photo *models.StoredPhoto) {
+	if photo == nil {
+		return
+	}
+	for _, key := range []string{photo.Key, photo.ThumbnailKey} {
+		if err := storage.Files.Delete(ctx, key); err != nil {
+			log.Println("deleting photo blob", key+":", err)
+		}
+	}
+}
+
+// readPhotoUpload reads the multipart "photo" field, enforcing the size limit
+func readPhotoUpload(c *fiber.Ctx) ([]byte, int, string) {
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return nil, 400, "Multipart field \"photo\" is required"
+	}
+	if file.Size > int64(config.MaxPhotoUploadBytes) {
+		return nil, 413, "Photo is too large"
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, 400, "Cannot read photo"
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(config.MaxPhotoUploadBytes)+1))
+	if err != nil {
+		return nil, 400, "Cannot read photo"
+	}
+	if len(data) > config.MaxPhotoUploadBytes {
+		return nil, 413, "Photo is too large"
+	}
+	return data, 0, ""
+}
+
+// loadGallery fetches the user whose gallery is being edited
+func loadGallery(ctx context.Context, c *fiber.Ctx) (*models.User, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var user models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{"error": "Error fetching user"})
+	}
+	return &user, nil
+}
+
+// saveGalleryOrder writes a reordered gallery, failing with 409 if the gallery changed since it was read
+func saveGalleryOrder(ctx context.Context, c *fiber.Ctx, user *models.User, photos []models.Photo) error {
+	filter := bson.M{"_id": user.ID, "version": versionFilter(user.Version)}
+	update := bson.M{
+		"$set": bson.M{"photos": photos, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	res, err := database.DB.Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update photos"})
+	}
+	if res.MatchedCount == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Photos were changed concurrently; reload and retry"})
+	}
+	return c.Status(200).JSON(visiblePhotos(user.ID, photos, user.ID))
+}
+
+// GET /users/:userId/photos - the user's gallery in display order
+func GetUserPhotos(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	user, err := loadGallery(ctx, c)
+	if user == nil {
+		return err
+	}
+	return c.Status(200).JSON(visiblePhotos(user.ID, user.Photos, viewerID(c)))
+}
+
+// POST /users/:userId/photos (multipart, field "photo") - add a photo to the end of the gallery.
+// JPEG and PNG are accepted; the image is re-encoded without metadata (EXIF location
+// included), scaled down and given a thumbnail. Unless PHOTO_AUTO_APPROVE is set, the
+// photo is only visible to its owner until a moderator approves it.
+func UploadUserPhoto(c *fiber.Ctx) error {
+	userObjectID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	data, status, msg := readPhotoUpload(c)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	exists, err := UserExists(userObjectID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check user existence"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	stored, status, msg := storePhoto(ctx, userObjectID, data)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+	photo := models.Photo{ID: primitive.NewObjectID(), StoredPhoto: *stored, ModerationStatus: models.PhotoPending}
+	if config.PhotoAutoApprove {
+		photo.ModerationStatus = models.PhotoApproved
+	}
+
+	// the cap is part of the filter so concurrent uploads cannot overshoot it
+	filter := bson.M{"_id": userObjectID, "photos." + strconv.Itoa(config.MaxProfilePhotos-1): bson.M{"$exists": false}}
+	update := bson.M{
+		"$push": bson.M{"photos": photo},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	res, err := database.DB.Collection("users").UpdateOne(ctx, filter, update)
+	if err != nil || res.MatchedCount == 0 {
+		deletePhotoBlobs(ctx, stored)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save photo"})
+		}
+		return c.Status(409).JSON(fiber.Map{"error": "Photo limit reached (" + strconv.Itoa(config.MaxProfilePhotos) + "); delete a photo first"})
+	}
+
+	presentPhoto(&photo.StoredPhoto)
+	return c.Status(201).JSON(photo)
+}
+
+// DELETE /users/:userId/photos/:photoId - remove a photo and its stored files
+func DeleteUserPhoto(c *fiber.Ctx) error {
+	userObjectID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	photoObjectID, err := primitive.ObjectIDFromHex(c.Params("photoId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid photo ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	// ReturnDocument Before gives us the storage keys of the removed photo
+	update := bson.M{
+		"$pull": bson.M{"photos": bson.M{"_id": photoObjectID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	var previous models.User
+	err = database.DB.Collection("users").FindOneAndUpdate(ctx, bson.M{"_id": userObjectID, "photos._id": photoObjectID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(404).JSON(fiber.Map{"error": "Photo not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete photo"})
+	}
+	for _, photo := range previous.Photos {
+		if photo.ID == photoObjectID {
+			deletePhotoBlobs(ctx, &photo.StoredPhoto)
+		}
+	}
+
+	return c.Status(200).JSON(fiber.Map{"message": "Photo deleted"})
+}
+
+// PUT /users/:userId/photos/order {"photoIds": [...]} - reorder the gallery.
+// The list must contain every photo of the gallery exactly once.
+func ReorderUserPhotos(c *fiber.Ctx) error {
+	var body struct {
+		PhotoIDs []string `json:"photoIds"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	user, err := loadGallery(ctx, c)
+	if user == nil {
+		return err
+	}
+	if len(body.PhotoIDs) != len(user.Photos) {
+		return c.Status(400).JSON(fiber.Map{"error": "photoIds must list every photo of the gallery exactly once"})
+	}
+	byID := make(map[string]models.Photo, len(user.Photos))
+	for _, photo := range user.Photos {
+		byID[photo.ID.Hex()] = photo
+	}
+	ordered := make([]models.Photo, 0, len(user.Photos))
+	for _, id := range body.PhotoIDs {
+		photo, ok := byID[id]
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"error": "photoIds must list every photo of the gallery exactly once"})
+		}
+		delete(byID, id)
+		ordered = append(ordered, photo)
+	}
+
+	return saveGalleryOrder(ctx, c, user, ordered)
+}
+
+// PUT /users/:userId/photos/:photoId/primary - move a photo to the front of the gallery.
+// A photo still pending moderation becomes the primary picture once it is approved.
+func SetPrimaryUserPhoto(c *fiber.Ctx) error {
+	photoID := c.Params("photoId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	user, err := loadGallery(ctx, c)
+	if user == nil {
+		return err
+	}
+	for i, photo := range user.Photos {
+		if photo.ID.Hex() != photoID {
+			continue
+		}
+		if photo.ModerationStatus == models.PhotoRejected {
+			return c.Status(400).JSON(fiber.Map{"error": "A rejected photo cannot be the primary photo"})
+		}
+		ordered := append([]models.Photo{photo}, user.Photos[:i]...)
+		ordered = append(ordered, user.Photos[i+1:]...)
+		return saveGalleryOrder(ctx, c, user, ordered)
+	}
+	return c.Status(404).JSON(fiber.Map{"error": "Photo not found"})
+}
+
+// GET /media/*?expires=&sig= - serve a stored object through a signed, expiring URL
+func GetMedia(c *fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil || key == "" || strings.Contains(key, "..") {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid media key"})
+	}
+	if err := utils.VerifyMediaSignature(key, c.Query("expires"), c.Query("sig"), config.AuthTokenSecret); err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	body, contentType, err := storage.Files.Get(ctx, key)
+	if err == storage.ErrNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read media"})
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read media"})
+	}
+
+	// stored objects never change (new uploads get new keys), so the URL can be cached until it expires
+	c.Set(fiber.HeaderContentType, contentType)
+	maxAge := int64(0)
+	if expires, err := strconv.ParseInt(c.Query("expires"), 10, 64); err == nil && expires > time.Now().Unix() {
+		maxAge = expires - time.Now().Unix()
+	}
+	c.Set(fiber.HeaderCacheControl, "private, max-age="+strconv.FormatInt(maxAge, 10)+", immutable")
+	return c.Status(200).Send(data)
+}
@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// last_seen_at is written on connect and disconnect, and at most this often while active
+const lastSeenWriteEvery = time.Minute
+
+// maxPresenceSubscriptions caps how many users one socket can watch
+const maxPresenceSubscriptions = 200
+
+// presenceState is what this server knows about a user's chat sockets
+type presenceState struct {
+	conns      int
+	lastActive time.Time
+	status     string    // as last sent to watchers
+	persisted  time.Time // last_seen_at as last written
+}
+
+// user id -> state, and user id -> sockets watching that user; both guarded by presenceMu.
+// Like the chat hub itself, presence only covers sockets on this server.
+var presenceStates = make(map[primitive.ObjectID]*presenceState)
+var presenceWatchers = make(map[primitive.ObjectID]map[*ChatConn]bool)
+var presenceMu sync.Mutex
+
+// presenceStatus is online while the user was active within PresenceAwayAfter, away while
+// they stay connected without activity and offline once their last socket closes
+func presenceStatus(st *presenceState, now time.Time) string {
+	switch {
+	case st == nil || st.conns == 0:
+		return models.PresenceOffline
+	case now.Sub(st.lastActive) >= config.PresenceAwayAfter:
+		return models.PresenceAway
+	default:
+		return models.PresenceOnline
+	}
+}
+
+// presenceChange records st's current status, reporting whether watchers need to hear about it.
+// presenceMu must be held.
+func presenceChange(st *presenceState, now time.Time) (models.Presence, bool) {
+	status := presenceStatus(st, now)
+	lastActive := st.lastActive
+	p := models.Presence{Status: status, LastSeenAt: &lastActive}
+	if status == st.status {
+		return p, false
+	}
+	st.status = status
+	return p, true
+}
+
+// presenceConnected counts a new chat socket for userID
+func presenceConnected(userID primitive.ObjectID, now time.Time) {
+	presenceMu.Lock()
+	st := presenceStates[userID]
+	if st == nil {
+		st = &presenceState{status: models.PresenceOffline}
+		presenceStates[userID] = st
+	}
+	st.conns++
+	st.lastActive = now
+	st.persisted = now
+	p, changed := presenceChange(st, now)
+	presenceMu.Unlock()
+
+	persistLastSeen(userID, now)
+	if changed {
+		go broadcastPresence(userID, p)
+	}
+}
+
+// presenceActive marks userID as active: they sent a message or a non-idle heartbeat
+func presenceActive(userID primitive.ObjectID, now time.Time) {
+	presenceMu.Lock()
+	st := presenceStates[userID]
+	if st == nil {
+		presenceMu.Unlock()
+		return
+	}
+	st.lastActive = now
+	persist := now.Sub(st.persisted) >= lastSeenWriteEvery
+	if persist {
+		st.persisted = now
+	}
+	p, changed := presenceChange(st, now)
+	presenceMu.Unlock()
+
+	if persist {
+		persistLastSeen(userID, now)
+	}
+	if changed {
+		go broadcastPresence(userID, p)
+	}
+}
+
+// presenceDisconnected drops a closed socket and its subscriptions
+func presenceDisconnected(userID primitive.ObjectID, cc *ChatConn, now time.Time) {
+	presenceMu.Lock()
+	for _, id := range cc.watching {
+		delete(presenceWatchers[id], cc)
+		if len(presenceWatchers[id]) == 0 {
+			delete(presenceWatchers, id)
+		}
+	}
+	cc.watching = nil
+	st := presenceStates[userID]
+	if st == nil {
+		presenceMu.Unlock()
+		return
+	}
+	st.conns--
+	p, changed := presenceChange(st, now)
+	if st.conns <= 0 {
+		delete(presenceStates, userID)
+	}
+	lastActive := st.lastActive
+	presenceMu.Unlock()
+
+	persistLastSeen(userID, lastActive)
+	if changed {
+		go broadcastPresence(userID, p)
+	}
+}
+
+// persistLastSeen moves last_seen_at forward. It is not a profile edit, so neither
+// version nor updated_at change.
+func persistLastSeen(userID primitive.ObjectID, at time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	_, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$max": bson.M{"last_seen_at": at}})
+	if err != nil {
+		log.Println("saving last seen:", err)
+	}
+}
+
+// SweepPresenceEvery tells watchers about users who went away without closing their socket; it never returns
+func SweepPresenceEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		now := time.Now()
+		changes := map[primitive.ObjectID]models.Presence{}
+		presenceMu.Lock()
+		for id, st := range presenceStates {
+			if p, changed := presenceChange(st, now); changed {
+				changes[id] = p
+			}
+		}
+		presenceMu.Unlock()
+		for id, p := range changes {
+			broadcastPresence(id, p)
+		}
+	}
+}
+
+// presenceOf is user's presence as of now, from this server's sockets and the stored last_seen_at
+func presenceOf(user *models.User, now time.Time) models.Presence {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	st := presenceStates[user.ID]
+	p := models.Presence{Status: presenceStatus(st, now), LastSeenAt: user.LastSeenAt}
+	if st != nil && (p.LastSeenAt == nil || st.lastActive.After(*p.LastSeenAt)) {
+		lastActive := st.lastActive
+		p.LastSeenAt = &lastActive
+	}
+	return p
+}
+
+// presenceVisibleTo returns which of users share their presence with viewer, following their
+// chat.presenceVisibility setting. Blocks hide presence both ways; anonymous viewers see none.
+func presenceVisibleTo(ctx context.Context, viewer primitive.ObjectID, users []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	visible := map[primitive.ObjectID]bool{}
+	if viewer.IsZero() || len(users) == 0 {
+		return visible, nil
+	}
+	settings, err := loadSettingsFor(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := blockedWith(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+	var needChatted, needConnected []primitive.ObjectID
+	for _, id := range users {
+		if id == viewer {
+			visible[id] = true
+			continue
+		}
+		if containsObjectID(blocked, id) {
+			continue
+		}
+		switch settings[id].Chat.PresenceVisibility {
+		case models.PresenceNobody:
+		case models.PresenceChatted:
+			needChatted = append(needChatted, id)
+		case models.PresenceConnections:
+			needConnected = append(needConnected, id)
+		default:
+			visible[id] = true
+		}
+	}
+	chatted, err := usersChattedWith(ctx, viewer, needChatted)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range needChatted {
+		visible[id] = chatted[id]
+	}
+	if len(needConnected) > 0 {
+		connected, err := usersConnectedWith(ctx, viewer, needConnected)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range needConnected {
+			visible[id] = connected[id]
+		}
+	}
+	return visible, nil
+}
+
+// presentPresence sets presence on users other than the viewer who share it with them
+func presentPresence(ctx context.Context, users []models.User, viewer primitive.ObjectID) error {
+	ids := make([]primitive.ObjectID, 0, len(users))
+	for _, u := range users {
+		if u.ID != viewer {
+			ids = append(ids, u.ID)
+		}
+	}
+	visible, err := presenceVisibleTo(ctx, viewer, ids)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range users {
+		if users[i].ID != viewer && visible[users[i].ID] {
+			p := presenceOf(&users[i], now)
+			users[i].Presence = &p
+		}
+	}
+	return nil
+}
+
+// presenceTag folds presence into a viewer-specific ETag
+func presenceTag(p *models.Presence) string {
+	if p == nil {
+		return ""
+	}
+	if p.LastSeenAt == nil {
+		return p.Status
+	}
+	return p.Status + "@" + strconv.FormatInt(p.LastSeenAt.Unix(), 10)
+}
+
+// presenceMessage is a control frame clients send on the chat socket. Other frames are chat messages.
+//
+//	{"type":"heartbeat"}                    keeps the user online; "idle":true only keeps the socket alive
+//	{"type":"presence.subscribe","userIds":[...]}   start receiving presence events for chat partners
+//	{"type":"presence.unsubscribe","userIds":[...]}
+type presenceMessage struct {
+	Type    string   `json:"type"`
+	Idle    bool     `json:"idle"`
+	UserIDs []string `json:"userIds"`
+}
+
+// presenceEvent is sent to watchers when a user's presence changes, and once on subscribing
+type presenceEvent struct {
+	Type   string             `json:"type"` // presence
+	UserID primitive.ObjectID `json:"userId"`
+	models.Presence
+}
+
+// handlePresenceMessage handles msg if it is a presence control frame, reporting whether it was
+func handlePresenceMessage(cc *ChatConn, userID primitive.ObjectID, msg []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(msg), []byte("{")) {
+		return false
+	}
+	var pm presenceMessage
+	if err := json.Unmarshal(msg, &pm); err != nil {
+		return false
+	}
+	switch pm.Type {
+	case "heartbeat":
+		if !pm.Idle {
+			presenceActive(userID, time.Now())
+		}
+	case "presence.subscribe":
+		subscribePresence(cc, userID, parseObjectIDs(pm.UserIDs))
+	case "presence.unsubscribe":
+		unsubscribePresence(cc, parseObjectIDs(pm.UserIDs))
+	default:
+		return false
+	}
+	return true
+}
+
+// parseObjectIDs keeps the valid ids among hexes
+func parseObjectIDs(hexes []string) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, h := range hexes {
+		if oid, err := primitive.ObjectIDFromHex(h); err == nil && !containsObjectID(ids, oid) {
+			ids = append(ids, oid)
+		}
+	}
+	return ids
+}
+
+// subscribePresence lets the socket watch users who share a chat window with its user and their
+// presence, answering with a presence.subscribed frame and the current presence of each
+func subscribePresence(cc *ChatConn, viewer primitive.ObjectID, ids []primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	chatted, err := usersChattedWith(ctx, viewer, ids)
+	if err != nil {
+		log.Println("checking presence subscription:", err)
+		return
+	}
+	var partners []primitive.ObjectID
+	for _, id := range ids {
+		if chatted[id] {
+			partners = append(partners, id)
+		}
+	}
+	visible, err := presenceVisibleTo(ctx, viewer, partners)
+	if err != nil {
+		log.Println("checking presence subscription:", err)
+		return
+	}
+
+	subscribed, rejected := []primitive.ObjectID{}, []primitive.ObjectID{}
+	presenceMu.Lock()
+	for _, id := range ids {
+		switch {
+		case !visible[id]:
+			rejected = append(rejected, id)
+		case containsObjectID(cc.watching, id):
+			subscribed = append(subscribed, id)
+		case len(cc.watching) >= maxPresenceSubscriptions:
+			rejected = append(rejected, id)
+		default:
+			if presenceWatchers[id] == nil {
+				presenceWatchers[id] = make(map[*ChatConn]bool)
+			}
+			presenceWatchers[id][cc] = true
+			cc.watching = append(cc.watching, id)
+			subscribed = append(subscribed, id)
+		}
+	}
+	presenceMu.Unlock()
+
+	ack, _ := json.Marshal(fiber.Map{"type": "presence.subscribed", "userIds": subscribed, "rejected": rejected})
+	cc.WriteMessage(websocket.TextMessage, ack)
+	if len(subscribed) == 0 {
+		return
+	}
+	opts := options.Find().SetProjection(bson.M{"last_seen_at": 1})
+	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": subscribed}}, opts)
+	if err != nil {
+		log.Println("loading presence:", err)
+		return
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Println("loading presence:", err)
+		return
+	}
+	now := time.Now()
+	for i := range users {
+		data, _ := json.Marshal(presenceEvent{Type: "presence", UserID: users[i].ID, Presence: presenceOf(&users[i], now)})
+		cc.WriteMessage(websocket.TextMessage, data)
+	}
+}
+
+func unsubscribePresence(cc *ChatConn, ids []primitive.ObjectID) {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	var kept []primitive.ObjectID
+	for _, id := range cc.watching {
+		if !containsObjectID(ids, id) {
+			kept = append(kept, id)
+			continue
+		}
+		delete(presenceWatchers[id], cc)
+		if len(presenceWatchers[id]) == 0 {
+			delete(presenceWatchers, id)
+		}
+	}
+	cc.watching = kept
+}
+
+// broadcastPresence sends p to the sockets watching userID whose users may still see it;
+// watchers who may not (the setting changed since they subscribed) stay subscribed but hear nothing
+func broadcastPresence(userID primitive.ObjectID, p models.Presence) {
+	presenceMu.Lock()
+	byViewer := map[primitive.ObjectID][]*ChatConn{}
+	for cc := range presenceWatchers[userID] {
+		if viewer, err := primitive.ObjectIDFromHex(cc.UserID); err == nil {
+			byViewer[viewer] = append(byViewer[viewer], cc)
+		}
+	}
+	presenceMu.Unlock()
+	if len(byViewer) == 0 {
+		return
+	}
+
+	data, _ := json.Marshal(presenceEvent{Type: "presence", UserID: userID, Presence: p})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	for viewer, conns := range byViewer {
+		visible, err := presenceVisibleTo(ctx, viewer, []primitive.ObjectID{userID})
+		if err != nil {
+			log.Println("checking presence visibility:", err)
+			continue
+		}
+		if !visible[userID] {
+			continue
+		}
+		for _, cc := range conns {
+			if err := cc.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Println("presence broadcast error:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const profileViewDay = "2006-01-02"
+
+// recordProfileView notes that viewer looked at viewed's profile today (UTC). Viewers who
+// turned off discovery.recordProfileViews or are hidden from discovery leave no trace,
+// and neither do views between blocked users.
+func recordProfileView(ctx context.Context, viewer primitive.ObjectID, viewed primitive.ObjectID, now time.Time) error {
+	if viewer.IsZero() || viewer == viewed {
+		return nil
+	}
+	settings, err := loadUserSettings(ctx, viewer)
+	if err != nil {
+		return err
+	}
+	if !settings.Discovery.RecordProfileViews || settings.Discovery.Visibility == models.VisibilityHidden {
+		return nil
+	}
+	if blocked, err := isBlocked(ctx, viewer, viewed); err != nil || blocked {
+		return err
+	}
+	now = now.UTC()
+	filter := bson.M{"viewed_id": viewed, "viewer_id": viewer, "day": now.Format(profileViewDay)}
+	update := bson.M{
+		"$setOnInsert": bson.M{"viewed_at": now},
+		"$set":         bson.M{"last_viewed_at": now},
+	}
+	_, err = database.DB.Collection("profile_views").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil // a concurrent view created today's record first
+	}
+	return err
+}
+
+// recordProfileViewLater records the view without holding up the profile response
+func recordProfileViewLater(viewer primitive.ObjectID, viewed primitive.ObjectID) {
+	now := time.Now()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		defer cancel()
+		if err := recordProfileView(ctx, viewer, viewed, now); err != nil {
+			log.Println("recording profile view:", err)
+		}
+	}()
+}
+
+// profileViewer is one person in the "who viewed my profile" list
+type profileViewer struct {
+	User         *models.User `json:"user"`
+	LastViewedAt time.Time    `json:"lastViewedAt"`
+	Days         int          `json:"days"` // days on which they viewed the profile
+}
+
+// GET /users/:userId/profile-views?page=1&limit=20 - who viewed my profile, most recent first.
+// People blocked by or blocking the user are left out.
+func GetProfileViewers(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	page, limit, err := pageParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	blocked, err := blockedWith(ctx, userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch blocks"})
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"viewed_id": userID, "viewer_id": bson.M{"$nin": blocked}}},
+		bson.M{"$group": bson.M{
+			"_id":            "$viewer_id",
+			"last_viewed_at": bson.M{"$max": "$last_viewed_at"},
+			"days":           bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.D{{Key: "last_viewed_at", Value: -1}, {Key: "_id", Value: -1}}},
+		bson.M{"$facet": bson.M{
+			"results": bson.A{bson.M{"$skip": (page - 1) * limit}, bson.M{"$limit": limit}},
+			"total":   bson.A{bson.M{"$count": "n"}},
+		}},
+	}
+	var facets []struct {
+		Results []struct {
+			ViewerID     primitive.ObjectID `bson:"_id"`
+			LastViewedAt time.Time          `bson:"last_viewed_at"`
+			Days         int                `bson:"days"`
+		} `bson:"results"`
+		Total []struct {
+			N int64 `bson:"n"`
+		} `bson:"total"`
+	}
+	if err := aggregateAll(ctx, "profile_views", pipeline, &facets); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch profile views"})
+	}
+	results := []profileViewer{}
+	var total int64
+	if len(facets) > 0 {
+		if len(facets[0].Total) > 0 {
+			total = facets[0].Total[0].N
+		}
+		ids := make([]primitive.ObjectID, len(facets[0].Results))
+		for i, r := range facets[0].Results {
+			ids[i] = r.ViewerID
+		}
+		var users []models.User
+		if len(ids) > 0 {
+			cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch viewers"})
+			}
+			if err := cursor.All(ctx, &users); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to decode viewers"})
+			}
+		}
+		presentUsers(users, userID)
+		byID := make(map[primitive.ObjectID]*models.User, len(users))
+		for i := range users {
+			byID[users[i].ID] = &users[i]
+		}
+		for _, r := range facets[0].Results {
+			if user, ok := byID[r.ViewerID]; ok {
+				results = append(results, profileViewer{User: user, LastViewedAt: r.LastViewedAt, Days: r.Days})
+			}
+		}
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"results": results,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// profileViewBucket counts the views in one day or week; each viewer counts once per day
+type profileViewBucket struct {
+	Start   string `json:"start"` // YYYY-MM-DD
+	Views   int    `json:"views"`
+	Viewers int    `json:"viewers"`
+}
+
+// GET /users/:userId/profile-views/stats?days=30&interval=day|week - view counts over time.
+// Weeks start on Monday; the first one may be partial.
+func GetProfileViewStats(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	retentionDays := int(config.ProfileViewRetention / (24 * time.Hour))
+	days := min(30, retentionDays)
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > retentionDays {
+			return c.Status(400).JSON(fiber.Map{"error": "days must be between 1 and " + strconv.Itoa(retentionDays)})
+		}
+		days = n
+	}
+	interval := c.Query("interval", "day")
+	if interval != "day" && interval != "week" {
+		return c.Status(400).JSON(fiber.Map{"error": "interval must be day or week"})
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, 1-days)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"viewed_id": userID, "day": bson.M{"$gte": from.Format(profileViewDay)}}},
+		bson.M{"$group": bson.M{"_id": "$day", "viewers": bson.M{"$addToSet": "$viewer_id"}}},
+	}
+	var perDay []struct {
+		Day     string               `bson:"_id"`
+		Viewers []primitive.ObjectID `bson:"viewers"`
+	}
+	if err := aggregateAll(ctx, "profile_views", pipeline, &perDay); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count profile views"})
+	}
+	viewersOn := make(map[string][]primitive.ObjectID, len(perDay))
+	for _, d := range perDay {
+		viewersOn[d.Day] = d.Viewers
+	}
+
+	buckets := []profileViewBucket{}
+	bucketViewers := map[primitive.ObjectID]bool{}
+	allViewers := map[primitive.ObjectID]bool{}
+	totalViews := 0
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if len(buckets) == 0 || interval == "day" || day.Weekday() == time.Monday {
+			buckets = append(buckets, profileViewBucket{Start: day.Format(profileViewDay)})
+			bucketViewers = map[primitive.ObjectID]bool{}
+		}
+		b := &buckets[len(buckets)-1]
+		for _, id := range viewersOn[day.Format(profileViewDay)] {
+			b.Views++
+			totalViews++
+			if !bucketViewers[id] {
+				bucketViewers[id] = true
+				b.Viewers++
+			}
+			allViewers[id] = true
+		}
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"interval":      interval,
+		"from":          from.Format(profileViewDay),
+		"to":            today.Format(profileViewDay),
+		"totalViews":    totalViews,
+		"uniqueViewers": len(allViewers),
+		"buckets":       buckets,
+	})
+}
@@ -73,28 +73,10 @@ func SetProximityAvailability(c *fiber.Ctx) error {
 	}
 
 	// compute created and expiry times from availability date + start/end times
-	// expected formats: Date = "YYYY-MM-DD", StartTime/EndTime = "HH:MM" or "HH:MM:SS"
-	parseLayouts := []string{"2006-01-02 15:04", "2006-01-02 15:04:05"}
-	var startTime, endTime time.Time
-	var parseErr error
-	for _, layout := range parseLayouts {
-		startTime, parseErr = time.ParseInLocation(layout, avail.Date+" "+avail.StartTime, time.Local)
-		if parseErr == nil {
-			break
-		}
-	}
-	if parseErr != nil {
+	startTime, endTime, ok := availabilitySlot(avail)
+	if !ok {
 		// fallback to now
 		startTime = time.Now()
-	}
-	parseErr = nil
-	for _, layout := range parseLayouts {
-		endTime, parseErr = time.ParseInLocation(layout, avail.Date+" "+avail.EndTime, time.Local)
-		if parseErr == nil {
-			break
-		}
-	}
-	if parseErr != nil {
 		endTime = startTime.Add(time.Hour)
 	}
 
@@ -161,21 +143,53 @@ func GetAllActiveProximities(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 
+	if viewer := viewerID(c); !viewer.IsZero() {
+		if status, msg := checkDateOfBirth(ctx, viewer, "to see people nearby"); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+	}
 	cursor, err := database.DB.Collection("active_proximities").Find(ctx, bson.M{"expires_at": bson.M{"$gt": time.Now()}})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error fetching active proximities"})
 	}
 	defer cursor.Close(ctx)
 
+	var all []models.ActiveProximity
+	var userIDs []primitive.ObjectID
 	for cursor.Next(ctx) {
 		var p models.ActiveProximity
 		cursor.Decode(&p)
-		proximities = append(proximities, p)
+		all = append(all, p)
+		userIDs = append(userIDs, p.UserID)
+	}
+
+	// the same visibility rules as the nearby listing apply
+	visible, err := discoverableBy(ctx, viewerID(c), userIDs, true)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error filtering active proximities"})
+	}
+	for _, p := range all {
+		if visible[p.UserID] {
+			proximities = append(proximities, p)
+		}
 	}
 
 	return c.JSON(proximities)
 }
 
+// nearbyUser is one entry of the GetNearbyUsers response
+type nearbyUser struct {
+	UserID    primitive.ObjectID `json:"userId" bson:"user_id"`
+	Latitude  float64            `json:"latitude" bson:"latitude"`
+	Longitude float64            `json:"longitude" bson:"longitude"`
+	Radius    float64            `json:"radius" bson:"radius"`
+	Distance  float64            `json:"distanceMeters"`
+	// distance in the viewer's preferred unit (settings.discovery.distanceUnit)
+	DisplayDistance float64   `json:"distance"`
+	DistanceUnit    string    `json:"distanceUnit"`
+	ExpiresAt       time.Time `json:"expiresAt" bson:"expires_at"`
+}
+
 // GetNearbyUsers returns all active users within the requesting user's radius.
 func GetNearbyUsers(c *fiber.Ctx) error {
 	userID := c.Params("userId")
@@ -187,6 +201,14 @@ func GetNearbyUsers(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 
+	viewer, err := FindUser(ctx, userObjectID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if viewer.DateOfBirth == nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Set your date of birth to see people nearby"})
+	}
+
 	// Find the latest active proximity for the user
 	filterMe := bson.M{"user_id": userObjectID, "expires_at": bson.M{"$gt": time.Now()}}
 	var me models.ActiveProximity
@@ -202,14 +224,7 @@ func GetNearbyUsers(c *fiber.Ctx) error {
 	}
 	defer cursor.Close(ctx)
 
-	var nearby []struct {
-		UserID    primitive.ObjectID `json:"userId" bson:"user_id"`
-		Latitude  float64            `json:"latitude" bson:"latitude"`
-		Longitude float64            `json:"longitude" bson:"longitude"`
-		Radius    float64            `json:"radius" bson:"radius"`
-		Distance  float64            `json:"distanceMeters"`
-		ExpiresAt time.Time          `json:"expiresAt" bson:"expires_at"`
-	}
+	var nearby []nearbyUser
 
 	for cursor.Next(ctx) {
 		var other models.ActiveProximity
@@ -217,14 +232,7 @@ func GetNearbyUsers(c *fiber.Ctx) error {
 		d := utils.HaversineDistance(me.Latitude, me.Longitude, other.Latitude, other.Longitude)
 		// consider within user's radius
 		if d <= me.Radius {
-			nearby = append(nearby, struct {
-				UserID    primitive.ObjectID `json:"userId" bson:"user_id"`
-				Latitude  float64            `json:"latitude" bson:"latitude"`
-				Longitude float64            `json:"longitude" bson:"longitude"`
-				Radius    float64            `json:"radius" bson:"radius"`
-				Distance  float64            `json:"distanceMeters"`
-				ExpiresAt time.Time          `json:"expiresAt" bson:"expires_at"`
-			}{
+			nearby = append(nearby, nearbyUser{
 				UserID:    other.UserID,
 				Latitude:  other.Latitude,
 				Longitude: other.Longitude,
@@ -235,7 +243,49 @@ func GetNearbyUsers(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.Status(200).JSON(nearby)
+	if len(nearby) == 0 {
+		return c.Status(200).JSON(nearby)
+	}
+
+	// keep only people within the user's age preference whose own preference includes the user
+	candidateIDs := make([]primitive.ObjectID, 0, len(nearby))
+	for _, n := range nearby {
+		candidateIDs = append(candidateIDs, n.UserID)
+	}
+	filter := ageCompatibilityFilter(viewer, time.Now())
+	filter["_id"] = bson.M{"$in": candidateIDs}
+	compatibleIDs, err := database.DB.Collection("users").Distinct(ctx, "_id", filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error filtering nearby users"})
+	}
+	var compatible []primitive.ObjectID
+	for _, id := range compatibleIDs {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			compatible = append(compatible, oid)
+		}
+	}
+	// and only people who chose to be visible nearby, to this user
+	visible, err := discoverableBy(ctx, userObjectID, compatible, true)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error filtering nearby users"})
+	}
+	settings, err := loadUserSettings(ctx, userObjectID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching settings"})
+	}
+	filtered := nearby[:0]
+	for _, n := range nearby {
+		if visible[n.UserID] {
+			n.DistanceUnit = settings.Discovery.DistanceUnit
+			n.DisplayDistance = n.Distance / 1000
+			if n.DistanceUnit == models.DistanceMiles {
+				n.DisplayDistance = n.Distance / 1609.344
+			}
+			filtered = append(filtered, n)
+		}
+	}
+
+	return c.Status(200).JSON(filtered)
 }
 
 // UpdateProximityLocation updates the active proximity entry's latitude/longitude
@@ -299,3 +349,28 @@ func UpdateProximityLocation(c *fiber.Ctx) error {
 
 	return c.Status(200).JSON(updated)
 }
+
+// availabilitySlot reads an availability's start and end in server local time.
+// Expected formats: Date = "YYYY-MM-DD", StartTime/EndTime = "HH:MM" or "HH:MM:SS".
+// An open-ended slot lasts an hour; ok is false when the start cannot be parsed.
+func availabilitySlot(avail models.Availablility) (time.Time, time.Time, bool) {
+	parse := func(clock string) (time.Time, error) {
+		var t time.Time
+		var err error
+		for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+			if t, err = time.ParseInLocation(layout, avail.Date+" "+clock, time.Local); err == nil {
+				break
+			}
+		}
+		return t, err
+	}
+	start, err := parse(avail.StartTime)
+	if err != nil {
+		return start, start, false
+	}
+	end, err := parse(avail.EndTime)
+	if err != nil {
+		end = start.Add(time.Hour)
+	}
+	return start, end, true
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxReviewLength = 1000
+
+// raterAndRatee reads the rated user from :userId and the rater from ?raterId=
+func raterAndRatee(c *fiber.Ctx) (primitive.ObjectID, primitive.ObjectID, error) {
+	ratee, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return ratee, ratee, errors.New("Invalid user ID")
+	}
+	rater, err := primitive.ObjectIDFromHex(c.Query("raterId"))
+	if err != nil {
+		return rater, ratee, errors.New("Invalid raterId")
+	}
+	return rater, ratee, nil
+}
+
+// ratingRefusal is why a rating is not (or no longer) allowed; reason is machine readable
+type ratingRefusal struct {
+	status int
+	body   fiber.Map
+}
+
+func refuseRating(status int, reason string, msg string) *ratingRefusal {
+	return &ratingRefusal{status: status, body: fiber.Map{"error": msg, "reason": reason}}
+}
+
+// checkRatableMeeting allows a rating only for an accepted meeting between rater and ratee
+// whose time slot has ended, within config.RatingWindow of its end, once per rater
+func checkRatableMeeting(ctx context.Context, rater primitive.ObjectID, ratee primitive.ObjectID, meetingID primitive.ObjectID, now time.Time) *ratingRefusal {
+	var meetingReq models.MeetingRequest
+	err := database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": meetingID}).Decode(&meetingReq)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return refuseRating(404, "meeting_not_found", "Meeting request not found")
+	}
+	if err != nil {
+		return refuseRating(500, "error", "Failed to fetch meeting request")
+	}
+	if r := meetingRatingRefusal(meetingReq, rater, ratee); r != nil {
+		return r
+	}
+
+	var avail models.Availablility
+	if err := database.DB.Collection("availabilities").FindOne(ctx, bson.M{"_id": meetingReq.AvailabilityID}).Decode(&avail); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return refuseRating(403, "meeting_cancelled", "The time slot of this meeting was cancelled")
+		}
+		return refuseRating(500, "error", "Failed to fetch the meeting's availability")
+	}
+	_, end, ok := availabilitySlot(avail)
+	if !ok {
+		return refuseRating(403, "meeting_time_unknown", "The time of this meeting is unknown")
+	}
+	if now.Before(end) {
+		r := refuseRating(403, "meeting_not_finished", "You can rate this meeting once it is over, from "+end.Format(time.RFC3339))
+		r.body["ratableFrom"] = end
+		return r
+	}
+	if closes := end.Add(config.RatingWindow); now.After(closes) {
+		r := refuseRating(403, "rating_window_closed", "Ratings for this meeting closed on "+closes.Format(time.RFC3339))
+		r.body["ratableUntil"] = closes
+		return r
+	}
+	return nil
+}
+
+// meetingRatingRefusal checks the meeting request itself: rater and ratee are its two
+// participants, the target accepted it and the rater has not rated it yet
+func meetingRatingRefusal(meetingReq models.MeetingRequest, rater primitive.ObjectID, ratee primitive.ObjectID) *ratingRefusal {
+	participants := []primitive.ObjectID{meetingReq.RequesterID, meetingReq.TargetUserID}
+	if !containsObjectID(participants, rater) || !containsObjectID(participants, ratee) {
+		return refuseRating(403, "not_a_participant", "You can only rate the person you met in this meeting")
+	}
+	// requests answered before responded_by was recorded are trusted as accepted by the target
+	accepted := meetingReq.Status == "accepted" && (meetingReq.RespondedBy == nil || *meetingReq.RespondedBy == meetingReq.TargetUserID)
+	if !accepted {
+		return refuseRating(403, "meeting_not_accepted", "This meeting request is "+meetingReq.Status+"; only meetings accepted by the invited user can be rated")
+	}
+	if containsObjectID(meetingReq.RatedBy, rater) {
+		return refuseRating(409, "already_rated", "You already rated this meeting")
+	}
+	return nil
+}
+
+// POST /users/:userId/ratings?raterId=<hex>
+// Accepts JSON { "meetingRequestId": "<hex>", "rating": <float 0-5>, "review": "..." }.
+// Only participants of an accepted meeting can rate each other, after it ended, within
+// RATING_WINDOW and once per meeting; refusals carry a "reason" code. Each rater has one
+// rating per user: rating again after another meeting replaces the previous score and
+// review. Responds with the rated user, whose trust_score and users_rated are recomputed
+// right away.
+func RateUser(c *fiber.Ctx) error {
+	raterID, rateeID, err := raterAndRatee(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if raterID == rateeID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot rate yourself"})
+	}
+
+	var body struct {
+		MeetingRequestID string   `json:"meetingRequestId"`
+		Rating           *float64 `json:"rating"`
+		Review           string   `json:"review"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if body.MeetingRequestID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "meetingRequestId is required: you can only rate people you have met", "reason": "meeting_required"})
+	}
+	meetingID, err := primitive.ObjectIDFromHex(body.MeetingRequestID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid meetingRequestId"})
+	}
+	if body.Rating == nil || *body.Rating < 0 || *body.Rating > 5 {
+		return c.Status(400).JSON(fiber.Map{"error": "Rating must be between 0 and 5"})
+	}
+	review := strings.TrimSpace(body.Review)
+	if len(review) > maxReviewLength {
+		return c.Status(400).JSON(fiber.Map{"error": "review must be at most 1000 characters"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	for _, id := range []primitive.ObjectID{raterID, rateeID} {
+		exists, err := UserExists(id)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check user existence"})
+		}
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+	}
+
+	now := time.Now()
+	if refusal := checkRatableMeeting(ctx, raterID, rateeID, meetingID, now); refusal != nil {
+		return c.Status(refusal.status).JSON(refusal.body)
+	}
+	// claiming the meeting first makes "once per meeting" hold under concurrent requests
+	res, err := database.DB.Collection("meeting_requests").UpdateOne(ctx,
+		bson.M{"_id": meetingID, "status": "accepted", "rated_by": bson.M{"$ne": raterID}},
+		bson.M{"$addToSet": bson.M{"rated_by": raterID}, "$inc": bson.M{"version": 1}, "$set": bson.M{"updated_at": now}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save rating"})
+	}
+	if res.ModifiedCount == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "You already rated this meeting", "reason": "already_rated"})
+	}
+	unclaim := func() {
+		database.DB.Collection("meeting_requests").UpdateOne(ctx, bson.M{"_id": meetingID},
+			bson.M{"$pull": bson.M{"rated_by": raterID}, "$inc": bson.M{"version": 1}})
+	}
+
+	// the previous rating (if any) tells a first rating from a replaced one
+	set := bson.M{"score": *body.Rating, "meeting_request_id": meetingID, "updated_at": now}
+	update := bson.M{"$set": set, "$setOnInsert": bson.M{"created_at": now}}
+	if review == "" {
+		update["$unset"] = bson.M{"review": ""}
+	} else {
+		set["review"] = review
+	}
+	filter := bson.M{"rater_id": raterID, "ratee_id": rateeID}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	var previous models.Rating
+	err = database.DB.Collection("ratings").FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent first rating won the upsert; ours now replaces it
+		err = database.DB.Collection("ratings").FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	}
+	created := errors.Is(err, mongo.ErrNoDocuments)
+	if err != nil && !created {
+		unclaim()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save rating"})
+	}
+
+	user, err := refreshTrustScore(ctx, rateeID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user trust score"})
+	}
+
+	presentUser(user, viewerID(c))
+	if created {
+		return c.Status(201).JSON(user)
+	}
+	return c.Status(200).JSON(user)
+}
+
+// DELETE /users/:userId/ratings?raterId=<hex> - withdraw a rating and its review
+func DeleteRating(c *fiber.Ctx) error {
+	raterID, rateeID, err := raterAndRatee(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	var rating models.Rating
+	err = database.DB.Collection("ratings").FindOneAndDelete(ctx, bson.M{"rater_id": raterID, "ratee_id": rateeID}).Decode(&rating)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(fiber.Map{"error": "You have not rated this user"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete rating"})
+	}
+	if _, err := refreshTrustScore(ctx, rateeID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user trust score"})
+	}
+	return c.Status(200).JSON(fiber.Map{"message": "Rating deleted"})
+}
+
+// review is a rating as listed on the rated user's profile
+type review struct {
+	models.Rating
+	RaterName string `json:"raterName"`
+}
+
+// GET /users/:userId/reviews?page=1&limit=20 - written reviews about a user, newest first
+func GetUserReviews(c *fiber.Ctx) error {
+	rateeID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	page, limit, err := pageParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	// reviews by people hidden from the viewer (blocked, suspended...) are left out
+	filter := bson.M{"ratee_id": rateeID, "review": bson.M{"$exists": true}}
+	raters, err := database.DB.Collection("ratings").Distinct(ctx, "rater_id", filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+	}
+	raterIDs := make([]primitive.ObjectID, 0, len(raters))
+	for _, id := range raters {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			raterIDs = append(raterIDs, oid)
+		}
+	}
+	hidden, err := hiddenFrom(ctx, viewerID(c), raterIDs, false)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+	}
+	filter["rater_id"] = bson.M{"$nin": hidden}
+	total, err := database.DB.Collection("ratings").CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}).SetSkip((page - 1) * limit).SetLimit(limit)
+	cursor, err := database.DB.Collection("ratings").Find(ctx, filter, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+	}
+	var ratings []models.Rating
+	if err := cursor.All(ctx, &ratings); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode reviews"})
+	}
+
+	raterIDs = make([]primitive.ObjectID, len(ratings))
+	for i, r := range ratings {
+		raterIDs[i] = r.RaterID
+	}
+	names := map[primitive.ObjectID]string{}
+	if len(raterIDs) > 0 {
+		cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": raterIDs}}, options.Find().SetProjection(bson.M{"name": 1}))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviewers"})
+		}
+		var raters []models.User
+		if err := cursor.All(ctx, &raters); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode reviewers"})
+		}
+		for _, r := range raters {
+			names[r.ID] = r.Name
+		}
+	}
+	reviews := make([]review, len(ratings))
+	for i, r := range ratings {
+		reviews[i] = review{Rating: r, RaterName: names[r.RaterID]}
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"results": reviews,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"testing"
+
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckMeetingResponse(t *testing.T) {
+	requester, target := primitive.NewObjectID(), primitive.NewObjectID()
+	req := models.MeetingRequest{RequesterID: requester, TargetUserID: target, Status: "pending"}
+
+	if status, _ := checkMeetingResponse(req, target); status != 0 {
+		t.Errorf("target answering: got status %d, want 0", status)
+	}
+	if status, _ := checkMeetingResponse(req, requester); status != 403 {
+		t.Errorf("requester answering: got status %d, want 403", status)
+	}
+	if status, _ := checkMeetingResponse(req, primitive.NewObjectID()); status != 403 {
+		t.Errorf("stranger answering: got status %d, want 403", status)
+	}
+	req.Status = "deleted"
+	if status, _ := checkMeetingResponse(req, target); status != 409 {
+		t.Errorf("answering a cancelled request: got status %d, want 409", status)
+	}
+}
+
+func TestMeetingRatingRefusal(t *testing.T) {
+	requester, target := primitive.NewObjectID(), primitive.NewObjectID()
+	accepted := func(respondedBy *primitive.ObjectID) models.MeetingRequest {
+		return models.MeetingRequest{RequesterID: requester, TargetUserID: target, Status: "accepted", RespondedBy: respondedBy}
+	}
+
+	tests := []struct {
+		name   string
+		req    models.MeetingRequest
+		rater  primitive.ObjectID
+		ratee  primitive.ObjectID
+		reason string // "" when the rating is allowed
+	}{
+		{"accepted by target", accepted(&target), requester, target, ""},
+		{"target rates requester", accepted(&target), target, requester, ""},
+		{"accepted before responded_by was recorded", accepted(nil), requester, target, ""},
+		{"accepted by the requester", accepted(&requester), requester, target, "meeting_not_accepted"},
+		{"pending", models.MeetingRequest{RequesterID: requester, TargetUserID: target, Status: "pending"}, requester, target, "meeting_not_accepted"},
+		{"rejected", models.MeetingRequest{RequesterID: requester, TargetUserID: target, Status: "rejected", RespondedBy: &target}, requester, target, "meeting_not_accepted"},
+		{"outsider rater", accepted(&target), primitive.NewObjectID(), target, "not_a_participant"},
+		{"outsider ratee", accepted(&target), requester, primitive.NewObjectID(), "not_a_participant"},
+		{"already rated", models.MeetingRequest{RequesterID: requester, TargetUserID: target, Status: "accepted", RespondedBy: &target, RatedBy: []primitive.ObjectID{requester}}, requester, target, "already_rated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := meetingRatingRefusal(tt.req, tt.rater, tt.ratee)
+			switch {
+			case tt.reason == "" && r != nil:
+				t.Errorf("got refusal %v, want none", r.body["reason"])
+			case tt.reason != "" && r == nil:
+				t.Errorf("got no refusal, want %s", tt.reason)
+			case tt.reason != "" && r.body["reason"] != tt.reason:
+				t.Errorf("got reason %v, want %s", r.body["reason"], tt.reason)
+			}
+		})
+	}
+}
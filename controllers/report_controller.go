@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxReportDetails = 2000
+
+func validReportReason(reason string) bool {
+	for _, r := range models.ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// reportTarget resolves what reporter is reporting to the accused user and an evidence
+// snapshot. Reporters can only report messages and meeting requests they were party to.
+func reportTarget(ctx context.Context, reporter primitive.ObjectID, targetType string, targetID primitive.ObjectID) (primitive.ObjectID, models.ReportEvidence, int, string) {
+	evidence := models.ReportEvidence{CapturedAt: time.Now()}
+	var reported primitive.ObjectID
+
+	switch targetType {
+	case models.ReportTargetUser:
+		reported = targetID
+	case models.ReportTargetMessage:
+		var msg models.Chat
+		if err := database.DB.Collection("chats").FindOne(ctx, bson.M{"_id": targetID}).Decode(&msg); err != nil {
+			return reported, evidence, 404, "Message not found"
+		}
+		count, err := database.DB.Collection("chat_windows").CountDocuments(ctx, bson.M{"_id": msg.ChatWindowID, "participant_ids": reporter})
+		if err != nil {
+			return reported, evidence, 500, "Failed to load chat window"
+		}
+		if count == 0 {
+			return reported, evidence, 404, "Message not found"
+		}
+		reported = msg.CreatedBy
+		sentAt := msg.CreatedAt
+		evidence.ChatWindowID = &msg.ChatWindowID
+		evidence.MessageText = msg.Msg
+		evidence.MessageSentAt = &sentAt
+	case models.ReportTargetMeetingRequest:
+		var meetingReq models.MeetingRequest
+		err := database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{
+			"_id": targetID,
+			"$or": bson.A{bson.M{"requester_id": reporter}, bson.M{"target_user_id": reporter}},
+		}).Decode(&meetingReq)
+		if err != nil {
+			return reported, evidence, 404, "Meeting request not found"
+		}
+		reported = meetingReq.RequesterID
+		if reported == reporter {
+			reported = meetingReq.TargetUserID
+		}
+		evidence.MeetingMessage = meetingReq.Message
+		evidence.MeetingStatus = meetingReq.Status
+	default:
+		return reported, evidence, 400, "targetType must be user, message or meeting_request"
+	}
+
+	if reported == reporter {
+		return reported, evidence, 400, "You cannot report yourself"
+	}
+	user, err := FindUser(ctx, reported)
+	if err != nil {
+		return reported, evidence, 404, "User not found"
+	}
+	evidence.UserName = user.Name
+	evidence.UserBio = user.Bio
+	return reported, evidence, 0, ""
+}
+
+// POST /reports {"targetType": "user|message|meeting_request", "targetId": "<hex>", "reason": "...", "details": "..."}
+// Reporting the same target again while the first report is still open returns that report.
+func CreateReport(c *fiber.Ctx) error {
+	reporter, _ := middleware.CurrentUserID(c)
+	var req struct {
+		TargetType string `json:"targetType"`
+		TargetID   string `json:"targetId"`
+		Reason     string `json:"reason"`
+		Details    string `json:"details"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	targetID, err := primitive.ObjectIDFromHex(req.TargetID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid targetId"})
+	}
+	if !validReportReason(req.Reason) {
+		return c.Status(400).JSON(fiber.Map{"error": "reason must be one of " + strings.Join(models.ReportReasons, ", ")})
+	}
+	details := strings.TrimSpace(req.Details)
+	if req.Reason == "other" && details == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "details are required when reason is other"})
+	}
+	if req.Reason == models.ReportReasonNoShow && req.TargetType != models.ReportTargetMeetingRequest {
+		return c.Status(400).JSON(fiber.Map{"error": "no_show reports must be about a meeting request"})
+	}
+	if len(details) > maxReportDetails {
+		return c.Status(400).JSON(fiber.Map{"error": "details must be at most 2000 characters"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	reported, evidence, status, msg := reportTarget(ctx, reporter, req.TargetType, targetID)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+	if req.Reason == models.ReportReasonNoShow && evidence.MeetingStatus != "accepted" {
+		return c.Status(400).JSON(fiber.Map{"error": "Only accepted meetings can be reported as a no-show"})
+	}
+
+	now := time.Now()
+	report := models.Report{
+		ReporterID:     reporter,
+		ReportedUserID: reported,
+		TargetType:     req.TargetType,
+		TargetID:       targetID,
+		Reason:         req.Reason,
+		Details:        details,
+		Evidence:       evidence,
+		Status:         models.ReportOpen,
+		Version:        1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	filter := bson.M{
+		"reporter_id": reporter,
+		"target_type": req.TargetType,
+		"target_id":   targetID,
+		"status":      bson.M{"$in": bson.A{models.ReportOpen, models.ReportInReview}},
+	}
+	res, err := database.DB.Collection("reports").UpdateOne(ctx, filter, bson.M{"$setOnInsert": report}, options.Update().SetUpsert(true))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to file report"})
+	}
+	if res.UpsertedCount == 0 {
+		if err := database.DB.Collection("reports").FindOne(ctx, filter).Decode(&report); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to file report"})
+		}
+		return c.Status(200).JSON(report)
+	}
+	report.ID = res.UpsertedID.(primitive.ObjectID)
+	if report.Reason == models.ReportReasonNoShow {
+		refreshTrustScoreLater(report.ReportedUserID)
+	}
+	return c.Status(201).JSON(report)
+}
+
+// GET /users/:userId/reports - reports this user filed, most recent first
+func GetReportsByUser(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := database.DB.Collection("reports").Find(ctx, bson.M{"reporter_id": userID}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports"})
+	}
+	reports := []models.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode reports"})
+	}
+	// how a report was handled is between the moderators and the reported user
+	for i := range reports {
+		reports[i].AssigneeID = nil
+		reports[i].Resolution = nil
+	}
+	return c.Status(200).JSON(reports)
+}
+
+// GET /users/:userId/warnings - warnings moderators issued to this user
+func GetUserWarnings(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := database.DB.Collection("user_warnings").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch warnings"})
+	}
+	warnings := []models.UserWarning{}
+	if err := cursor.All(ctx, &warnings); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode warnings"})
+	}
+	return c.Status(200).JSON(warnings)
+}
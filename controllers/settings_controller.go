@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+	_ "time/tzdata" // timezone settings are validated without relying on the host's zoneinfo
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultUserSettings are the settings of a user who never changed any
+func defaultUserSettings(userID primitive.ObjectID) models.UserSettings {
+	return models.UserSettings{
+		UserID: userID,
+		Discovery: models.DiscoverySettings{
+			Visibility:         models.VisibilityEveryone,
+			VisibleInNearby:    true,
+			DistanceUnit:       models.DistanceKilometers,
+			RecordProfileViews: true,
+		},
+		Notifications: models.NotificationSettings{
+			Events: map[string][]string{
+				models.EventMeetingRequest:  {models.NotifyPush, models.NotifyEmail},
+				models.EventMeetingResponse: {models.NotifyPush},
+				models.EventChatMessage:     {models.NotifyPush},
+				models.EventRating:          {},
+			},
+			QuietHours: models.QuietHours{Start: "22:00", End: "07:00"},
+		},
+		Chat: models.ChatSettings{
+			WhoCanMessage:      models.MessageEveryone,
+			ReadReceipts:       true,
+			PresenceVisibility: models.PresenceEveryone,
+		},
+		Locale:   "en",
+		Timezone: "UTC",
+	}
+}
+
+// loadUserSettings returns a user's settings, with defaults for anything never stored
+func loadUserSettings(ctx context.Context, userID primitive.ObjectID) (models.UserSettings, error) {
+	settings := defaultUserSettings(userID)
+	// decoding over the defaults keeps them for fields missing from the document
+	err := database.DB.Collection("user_settings").FindOne(ctx, bson.M{"_id": userID}).Decode(&settings)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// loadSettingsFor returns the settings of several users, keyed by user id
+func loadSettingsFor(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID]models.UserSettings, error) {
+	settings := make(map[primitive.ObjectID]models.UserSettings, len(userIDs))
+	for _, id := range userIDs {
+		settings[id] = defaultUserSettings(id)
+	}
+	cursor, err := database.DB.Collection("user_settings").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var id struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&id); err != nil {
+			return nil, err
+		}
+		s := defaultUserSettings(id.ID)
+		if err := cursor.Decode(&s); err != nil {
+			return nil, err
+		}
+		settings[id.ID] = s
+	}
+	return settings, cursor.Err()
+}
+
+func settingsETag(settings models.UserSettings) string {
+	return utils.StrongETag("user_settings", settings.UserID.Hex(), strconv.FormatInt(settings.Version, 10))
+}
+
+var (
+	clockPattern  = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+)
+
+// validateUserSettings checks every setting, returning the first problem found
+func validateUserSettings(s *models.UserSettings) error {
+	switch s.Discovery.Visibility {
+	case models.VisibilityEveryone, models.VisibilitySharedInterests, models.VisibilityChatted, models.VisibilityConnections, models.VisibilityHidden:
+	default:
+		return errors.New("discovery.visibility must be everyone, shared_interests, chatted, connections or hidden")
+	}
+	if s.Discovery.DistanceUnit != models.DistanceKilometers && s.Discovery.DistanceUnit != models.DistanceMiles {
+		return errors.New("discovery.distanceUnit must be km or mi")
+	}
+	for event, channels := range s.Notifications.Events {
+		switch event {
+		case models.EventMeetingRequest, models.EventMeetingResponse, models.EventChatMessage, models.EventRating:
+		default:
+			return errors.New("notifications.events: unknown event " + event)
+		}
+		seen := map[string]bool{}
+		for _, channel := range channels {
+			if channel != models.NotifyPush && channel != models.NotifyEmail {
+				return errors.New("notifications.events." + event + ": channels must be push or email")
+			}
+			if seen[channel] {
+				return errors.New("notifications.events." + event + ": duplicate channel " + channel)
+			}
+			seen[channel] = true
+		}
+		if channels == nil {
+			s.Notifications.Events[event] = []string{}
+		}
+	}
+	if !clockPattern.MatchString(s.Notifications.QuietHours.Start) || !clockPattern.MatchString(s.Notifications.QuietHours.End) {
+		return errors.New("notifications.quietHours start and end must be HH:MM")
+	}
+	switch s.Chat.WhoCanMessage {
+	case models.MessageEveryone, models.MessageSharedInterests, models.MessageConnections, models.MessageNobody:
+	default:
+		return errors.New("chat.whoCanMessage must be everyone, shared_interests, connections or nobody")
+	}
+	switch s.Chat.PresenceVisibility {
+	case models.PresenceEveryone, models.PresenceChatted, models.PresenceConnections, models.PresenceNobody:
+	default:
+		return errors.New("chat.presenceVisibility must be everyone, chatted, connections or nobody")
+	}
+	if !localePattern.MatchString(s.Locale) {
+		return errors.New("locale must be a language tag such as en or pt-BR")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" || s.Timezone == "Local" {
+		return errors.New("timezone must be an IANA time zone such as Europe/Berlin")
+	}
+	return nil
+}
+
+// GET /users/:userId/settings
+func GetUserSettings(c *fiber.Ctx) error {
+	userObjectID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	settings, err := loadUserSettings(ctx, userObjectID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch settings"})
+	}
+	if notModified(c, settingsETag(settings)) {
+		return c.SendStatus(304)
+	}
+	return c.Status(200).JSON(settings)
+}
+
+// PATCH /users/:userId/settings - JSON Merge Patch (RFC 7396) of the settings.
+// Nested sections are merged, null resets a setting to its default and unknown
+// settings are rejected. Honours If-Match like the other conditional updates.
+func UpdateUserSettings(c *fiber.Ctx) error {
+	userObjectID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &patch); err != nil || patch == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Body must be a JSON object"})
+	}
+	for _, readOnly := range []string{"userId", "version", "updatedAt"} {
+		if _, ok := patch[readOnly]; ok {
+			return c.Status(400).JSON(fiber.Map{"error": readOnly + " cannot be changed"})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	exists, err := UserExists(userObjectID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check user existence"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	current, err := loadUserSettings(ctx, userObjectID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch settings"})
+	}
+	if !preconditionHolds(c, settingsETag(current), current.Version, nil) {
+		return preconditionFailed(c, settingsETag(current), current)
+	}
+
+	// apply the patch to the JSON form of the current settings, then decode the result
+	// over fresh defaults so removed members fall back to them
+	var doc interface{}
+	raw, _ := json.Marshal(current)
+	json.Unmarshal(raw, &doc)
+	merged, _ := json.Marshal(utils.MergePatch(doc, patch))
+	updated := defaultUserSettings(userObjectID)
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updated); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid settings: " + err.Error()})
+	}
+	if err := validateUserSettings(&updated); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	updated.UserID = userObjectID
+	updated.Version = current.Version + 1
+	updated.UpdatedAt = time.Now()
+
+	filter := bson.M{"_id": userObjectID, "version": versionFilter(current.Version)}
+	_, err = database.DB.Collection("user_settings").ReplaceOne(ctx, filter, updated, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// the version filter missed an existing document: someone else saved first
+		latest, err := loadUserSettings(ctx, userObjectID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch settings"})
+		}
+		return preconditionFailed(c, settingsETag(latest), latest)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save settings"})
+	}
+
+	if current.Discovery.RecordProfileViews && !updated.Discovery.RecordProfileViews {
+		// opting out also erases the traces already left
+		if _, err := database.DB.Collection("profile_views").DeleteMany(ctx, bson.M{"viewer_id": userObjectID}); err != nil {
+			log.Println("erasing profile views:", err)
+		}
+	}
+
+	c.Set(fiber.HeaderETag, settingsETag(updated))
+	return c.Status(200).JSON(updated)
+}
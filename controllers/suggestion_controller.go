@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How candidates are scored; shared interests add ln(1 + users/usersWithTheInterest) each,
+// so a rare interest counts for more than a popular one
+const (
+	mutualConnectionWeight = 3.0
+	sharedEventWeight      = 2.0 // a group chat or meeting slot both attended
+	sameLocalityWeight     = 1.0
+	sameCityWeight         = 0.5
+)
+
+const (
+	// suggestionsTTL is how long a user's ranked suggestions are reused
+	suggestionsTTL = 10 * time.Minute
+	// maxSuggestions is how many ranked suggestions are kept per user
+	maxSuggestions = 100
+	// maxCandidatesPerSource bounds the candidates each graph query contributes
+	maxCandidatesPerSource = 500
+)
+
+// scoredCandidate is a suggested user with why they were suggested
+type scoredCandidate struct {
+	UserID            primitive.ObjectID
+	Score             float64
+	MutualConnections int
+	SharedInterests   []string
+	SharedEvents      int
+	Locality          string // set when it matches the viewer's locality or city
+}
+
+type cachedSuggestions struct {
+	candidates  []scoredCandidate
+	generatedAt time.Time
+}
+
+// suggestionsCache holds each user's ranked suggestions. Entries are dropped when the
+// user's connections or blocks change; visibility is re-checked on every read.
+var suggestionsCache = utils.NewLRU[primitive.ObjectID, cachedSuggestions](10000, suggestionsTTL)
+
+// forgetSuggestions drops the cached suggestions of users whose graph just changed
+func forgetSuggestions(userIDs ...primitive.ObjectID) {
+	for _, id := range userIDs {
+		suggestionsCache.Remove(id)
+	}
+}
+
+// suggestion is one entry of GET /users/:userId/suggestions
+type suggestion struct {
+	User              models.User `json:"user"`
+	Score             float64     `json:"score"`
+	MutualConnections int         `json:"mutualConnections"`
+	SharedInterests   []string    `json:"sharedInterests"`
+	SharedEvents      int         `json:"sharedEvents"`
+	Explanation       string      `json:"explanation"`
+}
+
+// explain sums up a suggestion, e.g. "3 shared interests, 2 mutual connections"
+func (s scoredCandidate) explain() string {
+	var parts []string
+	if n := len(s.SharedInterests); n > 0 {
+		parts = append(parts, plural(n, "shared interest"))
+	}
+	if s.MutualConnections > 0 {
+		parts = append(parts, plural(s.MutualConnections, "mutual connection"))
+	}
+	if s.SharedEvents > 0 {
+		parts = append(parts, plural(s.SharedEvents, "event")+" together")
+	}
+	if s.Locality != "" {
+		parts = append(parts, "also in "+s.Locality)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// interactedWith returns everyone userID already knows one way or another: blocks both
+// ways, connections and requests, one-to-one chats, meeting requests and ratings
+func interactedWith(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	known := map[primitive.ObjectID]bool{userID: true}
+	blocked, err := blockedWith(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blocked {
+		known[id] = true
+	}
+	lookups := []struct {
+		collection string
+		field      string
+		filter     bson.M
+	}{
+		{"connections", "user_ids", bson.M{"user_ids": userID}},
+		{"chat_windows", "participant_ids", bson.M{"participant_ids": userID, "is_group": bson.M{"$ne": true}}},
+		{"meeting_requests", "target_user_id", bson.M{"requester_id": userID}},
+		{"meeting_requests", "requester_id", bson.M{"target_user_id": userID}},
+		{"ratings", "ratee_id", bson.M{"rater_id": userID}},
+		{"ratings", "rater_id", bson.M{"ratee_id": userID}},
+	}
+	for _, l := range lookups {
+		ids, err := database.DB.Collection(l.collection).Distinct(ctx, l.field, l.filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if oid, ok := id.(primitive.ObjectID); ok {
+				known[oid] = true
+			}
+		}
+	}
+	return known, nil
+}
+
+// countedID decodes {_id, count} aggregation rows
+type countedID struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Count int                `bson:"count"`
+}
+
+// computeSuggestions ranks the people viewer may know: friends of friends, people with
+// the same (preferably rare) interests and people from the same group chats or meeting
+// slots, boosted when they live in the same area. People viewer already interacted with,
+// or who may not be shown to viewer, are left out.
+func computeSuggestions(ctx context.Context, viewer *models.User) ([]scoredCandidate, error) {
+	known, err := interactedWith(ctx, viewer.ID)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make([]primitive.ObjectID, 0, len(known))
+	for id := range known {
+		excluded = append(excluded, id)
+	}
+	candidates := map[primitive.ObjectID]*scoredCandidate{}
+	candidate := func(id primitive.ObjectID) *scoredCandidate {
+		if candidates[id] == nil {
+			candidates[id] = &scoredCandidate{UserID: id, SharedInterests: []string{}}
+		}
+		return candidates[id]
+	}
+
+	// friends of friends
+	connected, err := usersConnectedWith(ctx, viewer.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(connected) > 0 {
+		mine := make([]primitive.ObjectID, 0, len(connected))
+		for id := range connected {
+			mine = append(mine, id)
+		}
+		var rows []countedID
+		err = aggregateAll(ctx, "connections", bson.A{
+			bson.M{"$match": bson.M{"status": models.ConnectionAccepted, "user_ids": bson.M{"$in": mine}}},
+			bson.M{"$unwind": "$user_ids"},
+			bson.M{"$match": bson.M{"user_ids": bson.M{"$nin": excluded}}},
+			bson.M{"$group": bson.M{"_id": "$user_ids", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": maxCandidatesPerSource},
+		}, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			candidate(r.ID).MutualConnections = r.Count
+		}
+	}
+
+	// shared interests, the rarer the better
+	interestIDs, err := database.DB.Collection("user_interests").Distinct(ctx, "interest_id", bson.M{"user_id": viewer.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(interestIDs) > 0 {
+		weights, names, err := interestRarity(ctx, interestIDs)
+		if err != nil {
+			return nil, err
+		}
+		branches := bson.A{}
+		for id, w := range weights {
+			branches = append(branches, bson.M{"case": bson.M{"$eq": bson.A{"$interest_id", id}}, "then": w})
+		}
+		var rows []struct {
+			ID        primitive.ObjectID   `bson:"_id"`
+			Score     float64              `bson:"score"`
+			Interests []primitive.ObjectID `bson:"interests"`
+		}
+		err = aggregateAll(ctx, "user_interests", bson.A{
+			bson.M{"$match": bson.M{"interest_id": bson.M{"$in": interestIDs}, "user_id": bson.M{"$nin": excluded}}},
+			bson.M{"$group": bson.M{
+				"_id":       "$user_id",
+				"score":     bson.M{"$sum": bson.M{"$switch": bson.M{"branches": branches, "default": 0}}},
+				"interests": bson.M{"$addToSet": "$interest_id"},
+			}},
+			bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": maxCandidatesPerSource},
+		}, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			c := candidate(r.ID)
+			c.Score += r.Score
+			for _, id := range r.Interests {
+				if name, ok := names[id]; ok {
+					c.SharedInterests = append(c.SharedInterests, name)
+				}
+			}
+			sort.Strings(c.SharedInterests)
+		}
+	}
+
+	// group chats and meeting slots both were part of
+	var rows []countedID
+	err = aggregateAll(ctx, "chat_windows", bson.A{
+		bson.M{"$match": bson.M{"participant_ids": viewer.ID, "is_group": true}},
+		bson.M{"$unwind": "$participant_ids"},
+		bson.M{"$match": bson.M{"participant_ids": bson.M{"$nin": excluded}}},
+		bson.M{"$group": bson.M{"_id": "$participant_ids", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": maxCandidatesPerSource},
+	}, &rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		candidate(r.ID).SharedEvents += r.Count
+	}
+	slots, err := database.DB.Collection("meeting_requests").Distinct(ctx, "availability_id", bson.M{"requester_id": viewer.ID, "status": "accepted"})
+	if err != nil {
+		return nil, err
+	}
+	if len(slots) > 0 {
+		rows = nil
+		err = aggregateAll(ctx, "meeting_requests", bson.A{
+			bson.M{"$match": bson.M{"availability_id": bson.M{"$in": slots}, "status": "accepted", "requester_id": bson.M{"$nin": excluded}}},
+			bson.M{"$group": bson.M{"_id": "$requester_id", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": maxCandidatesPerSource},
+		}, &rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			candidate(r.ID).SharedEvents += r.Count
+		}
+	}
+	if len(candidates) == 0 {
+		return []scoredCandidate{}, nil
+	}
+
+	// only people the viewer may see and be matched with
+	ids := make([]primitive.ObjectID, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	allowed, err := discoverableBy(ctx, viewer.ID, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	filter := ageCompatibilityFilter(viewer, time.Now())
+	filter["_id"] = bson.M{"$in": ids}
+	cursor, err := database.DB.Collection("users").Find(ctx, filter, options.Find().SetProjection(bson.M{"locality": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	ranked := make([]scoredCandidate, 0, len(users))
+	for _, u := range users {
+		if !allowed[u.ID] {
+			continue
+		}
+		c := candidates[u.ID]
+		c.Score += mutualConnectionWeight*float64(c.MutualConnections) + sharedEventWeight*float64(c.SharedEvents)
+		switch {
+		case sameLocality(viewer.Locality, u.Locality):
+			c.Score += sameLocalityWeight
+			c.Locality = u.Locality
+		case sameLocality(localityCity(viewer.Locality), localityCity(u.Locality)):
+			c.Score += sameCityWeight
+			c.Locality = localityCity(u.Locality)
+		}
+		c.Score = math.Round(c.Score*100) / 100
+		ranked = append(ranked, *c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].UserID.Hex() < ranked[j].UserID.Hex()
+	})
+	if len(ranked) > maxSuggestions {
+		ranked = ranked[:maxSuggestions]
+	}
+	return ranked, nil
+}
+
+// interestRarity weighs each interest by ln(1 + users/usersWithTheInterest) and returns their names
+func interestRarity(ctx context.Context, interestIDs []interface{}) (map[primitive.ObjectID]float64, map[primitive.ObjectID]string, error) {
+	users, err := database.DB.Collection("users").EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []countedID
+	err = aggregateAll(ctx, "user_interests", bson.A{
+		bson.M{"$match": bson.M{"interest_id": bson.M{"$in": interestIDs}}},
+		bson.M{"$group": bson.M{"_id": "$interest_id", "count": bson.M{"$sum": 1}}},
+	}, &rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	weights := make(map[primitive.ObjectID]float64, len(rows))
+	for _, r := range rows {
+		weights[r.ID] = math.Log(1 + float64(max(users, 1))/float64(max(r.Count, 1)))
+	}
+
+	cursor, err := database.DB.Collection("interests").Find(ctx, bson.M{"_id": bson.M{"$in": interestIDs}}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	var interests []models.Interest
+	if err := cursor.All(ctx, &interests); err != nil {
+		return nil, nil, err
+	}
+	names := make(map[primitive.ObjectID]string, len(interests))
+	for _, i := range interests {
+		names[i.ID] = i.Name
+	}
+	return weights, names, nil
+}
+
+func sameLocality(a string, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	return a != "" && strings.EqualFold(a, b)
+}
+
+// localityCity is the last part of a "Neighbourhood, City" locality
+func localityCity(locality string) string {
+	parts := strings.Split(locality, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+// GET /users/:userId/suggestions?limit=20 - people the user may know, best first, each
+// with the reasons it was suggested. Rankings are cached for suggestionsTTL.
+func GetSuggestions(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > maxSuggestions {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", maxSuggestions)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	viewer, err := FindUser(ctx, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if viewer.DateOfBirth == nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Set your date of birth to get suggestions"})
+	}
+
+	cached, ok := suggestionsCache.Get(userID)
+	if !ok {
+		ranked, err := computeSuggestions(ctx, viewer)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to compute suggestions"})
+		}
+		cached = cachedSuggestions{candidates: ranked, generatedAt: time.Now()}
+		suggestionsCache.Add(userID, cached)
+	}
+
+	// settings, blocks and suspensions may have changed since the ranking was cached
+	ids := make([]primitive.ObjectID, len(cached.candidates))
+	for i, s := range cached.candidates {
+		ids[i] = s.UserID
+	}
+	allowed, err := discoverableBy(ctx, userID, ids, false)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch suggestions"})
+	}
+	var picked []scoredCandidate
+	for _, s := range cached.candidates {
+		if allowed[s.UserID] && len(picked) < limit {
+			picked = append(picked, s)
+		}
+	}
+	pickedIDs := make([]primitive.ObjectID, len(picked))
+	for i, s := range picked {
+		pickedIDs[i] = s.UserID
+	}
+	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": pickedIDs}})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch suggested users"})
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode suggested users"})
+	}
+	presentUsers(users, userID)
+	byID := make(map[primitive.ObjectID]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	results := []suggestion{}
+	for _, s := range picked {
+		user, ok := byID[s.UserID]
+		if !ok {
+			continue
+		}
+		mutual := s.MutualConnections
+		user.MutualConnections = &mutual
+		results = append(results, suggestion{
+			User:              user,
+			Score:             s.Score,
+			MutualConnections: s.MutualConnections,
+			SharedInterests:   s.SharedInterests,
+			SharedEvents:      s.SharedEvents,
+			Explanation:       s.explain(),
+		})
+	}
+	return c.Status(200).JSON(fiber.Map{"results": results, "generatedAt": cached.generatedAt})
+}
@@ -0,0 +1,399 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How much each signal moves a trust score, on top of the Bayesian average of ratings
+const (
+	legacyRatingWeight     = 0.5 // undated anonymous ratings count as one half-life old
+	verifiedEmailBonus     = 0.1
+	accountAgeBonus        = 0.2 // grows linearly until the account is accountAgeForFullBonus old
+	accountAgeForFullBonus = 365 * 24 * time.Hour
+	noShowPenalty          = 0.3 // per person who reported the user didn't turn up
+	maxNoShowPenalty       = 1.5
+	warningPenalty         = 0.25
+	suspensionPenalty      = 0.75
+	banPenalty             = 2.5
+	maxModerationPenalty   = 2.5
+)
+
+// reputationInputs are what a trust score is computed from
+type reputationInputs struct {
+	Ratings         int     `json:"ratings"`
+	WeightedRatings float64 `json:"weightedRatings"` // sum of the ratings' recency weights
+	WeightedTotal   float64 `json:"-"`               // sum of weight * score
+	LegacyRatings   int     `json:"legacyRatings"`
+	LegacyTotal     float64 `json:"-"`
+	EmailVerified   bool    `json:"emailVerified"`
+	AccountAgeDays  int     `json:"accountAgeDays"`
+	NoShowReports   int     `json:"noShowReports"` // distinct reporters, dismissed reports excluded
+	Warnings        int     `json:"warnings"`
+	Suspensions     int     `json:"suspensions"`
+	Bans            int     `json:"bans"`
+}
+
+// trustAdjustment is how much one signal added to or took from the Bayesian average
+type trustAdjustment struct {
+	Signal string  `json:"signal"`
+	Value  float64 `json:"value"`
+}
+
+// reputation is a trust score together with how it was reached
+type reputation struct {
+	UserID             primitive.ObjectID `json:"userId"`
+	TrustScore         float64            `json:"trustScore"`
+	UsersRated         int                `json:"usersRated"`
+	Inputs             reputationInputs   `json:"inputs"`
+	PriorMean          float64            `json:"priorMean"`
+	PriorWeight        float64            `json:"priorWeight"`
+	RatingHalfLifeDays float64            `json:"ratingHalfLifeDays"`
+	RatingsAverage     *float64           `json:"ratingsAverage"` // recency weighted, without the prior; null if unrated
+	BayesianAverage    float64            `json:"bayesianAverage"`
+	Adjustments        []trustAdjustment  `json:"adjustments"`
+	ComputedAt         time.Time          `json:"computedAt"`
+}
+
+// computeReputation turns the inputs into a trust score between 0 and 5. With few ratings
+// the score stays close to config.TrustPriorMean, so one 5-star rating can't outrank a
+// long record of good ones.
+func computeReputation(user *models.User, in reputationInputs, now time.Time) reputation {
+	weight := in.WeightedRatings + legacyRatingWeight*float64(in.LegacyRatings)
+	total := in.WeightedTotal + legacyRatingWeight*in.LegacyTotal
+	rep := reputation{
+		UserID:             user.ID,
+		UsersRated:         in.Ratings + in.LegacyRatings,
+		Inputs:             in,
+		PriorMean:          config.TrustPriorMean,
+		PriorWeight:        config.TrustPriorWeight,
+		RatingHalfLifeDays: config.TrustRatingHalfLife.Hours() / 24,
+		Adjustments:        []trustAdjustment{},
+		ComputedAt:         now,
+	}
+	if weight > 0 {
+		avg := roundScore(total / weight)
+		rep.RatingsAverage = &avg
+	}
+	bayesian := config.TrustPriorMean
+	if config.TrustPriorWeight+weight > 0 {
+		bayesian = (config.TrustPriorWeight*config.TrustPriorMean + total) / (config.TrustPriorWeight + weight)
+	}
+	rep.BayesianAverage = roundScore(bayesian)
+
+	adjust := func(signal string, value float64) {
+		if value != 0 {
+			rep.Adjustments = append(rep.Adjustments, trustAdjustment{Signal: signal, Value: roundScore(value)})
+		}
+	}
+	if in.EmailVerified {
+		adjust("email_verified", verifiedEmailBonus)
+	}
+	adjust("account_age", accountAgeBonus*min(float64(in.AccountAgeDays)*24/accountAgeForFullBonus.Hours(), 1))
+	adjust("no_show_reports", -min(noShowPenalty*float64(in.NoShowReports), maxNoShowPenalty))
+	moderation := warningPenalty*float64(in.Warnings) + suspensionPenalty*float64(in.Suspensions) + banPenalty*float64(in.Bans)
+	adjust("moderation_actions", -min(moderation, maxModerationPenalty))
+
+	score := bayesian
+	for _, a := range rep.Adjustments {
+		score += a.Value
+	}
+	rep.TrustScore = roundScore(min(max(score, 0), 5))
+	return rep
+}
+
+// roundScore keeps stored scores stable, so recomputing doesn't touch (and re-version)
+// users whose score only moved in the far decimals
+func roundScore(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// reputationInputsFor gathers the inputs of every given user with one query per source
+func reputationInputsFor(ctx context.Context, users []models.User, now time.Time) (map[primitive.ObjectID]reputationInputs, error) {
+	ids := make([]primitive.ObjectID, len(users))
+	inputs := make(map[primitive.ObjectID]reputationInputs, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+		in := reputationInputs{EmailVerified: u.EmailVerified}
+		if !u.CreatedAt.IsZero() && now.After(u.CreatedAt) {
+			in.AccountAgeDays = int(now.Sub(u.CreatedAt).Hours() / 24)
+		}
+		if u.LegacyRatings != nil {
+			in.LegacyRatings = u.LegacyRatings.Count
+			in.LegacyTotal = u.LegacyRatings.Total
+		}
+		inputs[u.ID] = in
+	}
+
+	// a rating's weight halves every TrustRatingHalfLife since it was last changed
+	age := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", "$created_at"}}}}}}
+	weight := bson.M{"$pow": bson.A{0.5, bson.M{"$divide": bson.A{age, config.TrustRatingHalfLife.Milliseconds()}}}}
+	var ratings []struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		Count  int                `bson:"count"`
+		Weight float64            `bson:"weight"`
+		Total  float64            `bson:"total"`
+	}
+	err := aggregateAll(ctx, "ratings", bson.A{
+		bson.M{"$match": bson.M{"ratee_id": bson.M{"$in": ids}}},
+		bson.M{"$group": bson.M{
+			"_id":    "$ratee_id",
+			"count":  bson.M{"$sum": 1},
+			"weight": bson.M{"$sum": weight},
+			"total":  bson.M{"$sum": bson.M{"$multiply": bson.A{weight, "$score"}}},
+		}},
+	}, &ratings)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range ratings {
+		in := inputs[r.ID]
+		in.Ratings, in.WeightedRatings, in.WeightedTotal = r.Count, r.Weight, r.Total
+		inputs[r.ID] = in
+	}
+
+	var noShows []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Reporters int                `bson:"reporters"`
+	}
+	err = aggregateAll(ctx, "reports", bson.A{
+		bson.M{"$match": bson.M{
+			"reported_user_id": bson.M{"$in": ids},
+			"reason":           models.ReportReasonNoShow,
+			"status":           bson.M{"$ne": models.ReportDismissed},
+		}},
+		bson.M{"$group": bson.M{"_id": "$reported_user_id", "reporters": bson.M{"$addToSet": "$reporter_id"}}},
+		bson.M{"$project": bson.M{"reporters": bson.M{"$size": "$reporters"}}},
+	}, &noShows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range noShows {
+		in := inputs[r.ID]
+		in.NoShowReports = r.Reporters
+		inputs[r.ID] = in
+	}
+
+	var actions []struct {
+		ID struct {
+			User   primitive.ObjectID `bson:"user"`
+			Action string             `bson:"action"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	err = aggregateAll(ctx, "moderation_events", bson.A{
+		bson.M{"$match": bson.M{
+			"subject_id": bson.M{"$in": ids},
+			"action":     bson.M{"$in": bson.A{models.ModerationWarn, models.ModerationSuspend, models.ModerationBan}},
+		}},
+		bson.M{"$group": bson.M{"_id": bson.M{"user": "$subject_id", "action": "$action"}, "count": bson.M{"$sum": 1}}},
+	}, &actions)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range actions {
+		in := inputs[a.ID.User]
+		switch a.ID.Action {
+		case models.ModerationWarn:
+			in.Warnings = a.Count
+		case models.ModerationSuspend:
+			in.Suspensions = a.Count
+		case models.ModerationBan:
+			in.Bans = a.Count
+		}
+		inputs[a.ID.User] = in
+	}
+	return inputs, nil
+}
+
+func aggregateAll(ctx context.Context, collection string, pipeline bson.A, results interface{}) error {
+	cursor, err := database.DB.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// errTrustScoreContention is returned when a user kept changing while their score was refreshed
+var errTrustScoreContention = errors.New("user changed too often while refreshing the trust score")
+
+// refreshTrustScore recomputes one user's trust score right away and returns the user.
+// The write is conditional on the version read, so a concurrent rating can't be
+// overwritten with a score computed before it.
+func refreshTrustScore(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		user, err := FindUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		inputs, err := reputationInputsFor(ctx, []models.User{*user}, now)
+		if err != nil {
+			return nil, err
+		}
+		rep := computeReputation(user, inputs[userID], now)
+		if user.TrustScore == rep.TrustScore && user.UsersRated == rep.UsersRated {
+			return user, nil
+		}
+		var updated models.User
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = database.DB.Collection("users").FindOneAndUpdate(ctx,
+			bson.M{"_id": userID, "version": versionFilter(user.Version)},
+			bson.M{
+				"$set": bson.M{"trust_score": rep.TrustScore, "users_rated": rep.UsersRated, "updated_at": now},
+				"$inc": bson.M{"version": 1},
+			}, opts).Decode(&updated)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	}
+	return nil, errTrustScoreContention
+}
+
+// refreshTrustScoreLater refreshes a trust score in the background, for requests that
+// shouldn't wait for (or fail on) the recompute
+func refreshTrustScoreLater(userID primitive.ObjectID) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		defer cancel()
+		if _, err := refreshTrustScore(ctx, userID); err != nil {
+			log.Printf("refreshing trust score of %s: %v", userID.Hex(), err)
+		}
+	}()
+}
+
+// RecomputeTrustScores recomputes trust_score/users_rated on the users matching filter.
+// Scores drift as ratings age and accounts get older, so this also runs periodically
+// (see RecomputeTrustScoresEvery). It returns the number of modified users.
+func RecomputeTrustScores(ctx context.Context, filter bson.M) (int64, error) {
+	opts := options.Find().SetProjection(bson.M{
+		"trust_score": 1, "users_rated": 1, "legacy_ratings": 1, "email_verified": 1, "created_at": 1, "version": 1,
+	})
+	cursor, err := database.DB.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var modified int64
+	batch := []models.User{}
+	flush := func() error {
+		n, err := recomputeTrustScoreBatch(ctx, batch)
+		modified += n
+		batch = batch[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return modified, err
+		}
+		batch = append(batch, user)
+		if len(batch) == 500 {
+			if err := flush(); err != nil {
+				return modified, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return modified, err
+	}
+	return modified, flush()
+}
+
+func recomputeTrustScoreBatch(ctx context.Context, users []models.User) (int64, error) {
+	if len(users) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	inputs, err := reputationInputsFor(ctx, users, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var updates []mongo.WriteModel
+	for i := range users {
+		u := &users[i]
+		rep := computeReputation(u, inputs[u.ID], now)
+		// bump the version (and so the profile ETag) only on users that actually change
+		if u.UsersRated == rep.UsersRated && u.TrustScore == rep.TrustScore {
+			continue
+		}
+		// users that changed since they were read are left to the next run
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": u.ID, "version": versionFilter(u.Version)}).
+			SetUpdate(bson.M{
+				"$set": bson.M{"users_rated": rep.UsersRated, "trust_score": rep.TrustScore},
+				"$inc": bson.M{"version": 1},
+			}))
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	res, err := database.DB.Collection("users").BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	if skipped := int64(len(updates)) - res.MatchedCount; skipped > 0 {
+		log.Printf("recomputing trust scores: %d users changed while being recomputed, left to the next run", skipped)
+	}
+	return res.ModifiedCount, nil
+}
+
+// RecomputeTrustScoresEvery recomputes every trust score right away and then after each
+// interval; it never returns
+func RecomputeTrustScoresEvery(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if n, err := RecomputeTrustScores(ctx, bson.M{}); err != nil {
+			log.Println("recomputing trust scores:", err)
+		} else if n > 0 {
+			log.Printf("recomputed %d trust scores", n)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// GET /users/:userId/trust - how the user's trust score is made up
+// It is computed live, so it can be slightly ahead of the stored trustScore.
+func GetTrustBreakdown(c *fiber.Ctx) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	user, err := FindUser(ctx, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+	now := time.Now()
+	inputs, err := reputationInputsFor(ctx, []models.User{*user}, now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to compute trust score"})
+	}
+	return c.JSON(computeReputation(user, inputs[userID], now))
+}
@@ -6,11 +6,15 @@ import (
 
 	"fast-af/config"
 	"fast-af/database"
+	"fast-af/middleware"
 	"fast-af/models"
+	"fast-af/utils"
 
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,6 +22,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
 )
 
 var googleOauthConfig = &config.GoogleOauthConfig
@@ -35,24 +40,137 @@ func GoogleCallback(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Code not found")
 	}
 
-	token, err := googleOauthConfig.Exchange(context.Background(), code)
+	user, created, err := googleUserFromCode(code)
 	if err != nil {
-		return c.Status(500).SendString("Failed to exchange token")
+		return c.Status(500).SendString(err.Error())
+	}
+	if underage(&user, time.Now()) {
+		return c.Status(403).SendString(fmt.Sprintf("You must be at least %d years old to use this app", config.MinimumAge))
+	}
+	if msg := middleware.AccountRestriction(&user, time.Now()); msg != "" {
+		return c.Status(403).SendString(msg)
+	}
+
+	// v1 clients only read the body; the token lets them move to /api/v2 without a second login
+	c.Set("X-Auth-Token", utils.IssueAuthToken(user.ID.Hex(), config.AuthTokenSecret, config.AuthTokenTTL))
+	if !created {
+		return c.Status(200).SendString(fmt.Sprintf("Welcome back, %s!", user.Name))
+	}
+	return c.Status(201).SendString(fmt.Sprintf("Welcome, %s!", user.Name))
+}
+
+// GET /api/v2/auth/google/login
+func GoogleLoginV2(c *fiber.Ctx) error {
+	url := googleOauthConfig.AuthCodeURL("randomstate", oauth2.SetAuthURLParam("redirect_uri", config.GoogleRedirectURLV2))
+	return c.Redirect(url)
+}
+
+// GET /api/v2/auth/google/callback
+// Responds with a bearer token for the Authorization header and the signed-in user
+func GoogleCallbackV2(c *fiber.Ctx) error {
+	code := c.Query("code")
+	if code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Code not found"})
+	}
+
+	user, created, err := googleUserFromCode(code, oauth2.SetAuthURLParam("redirect_uri", config.GoogleRedirectURLV2))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if underage(&user, time.Now()) {
+		return underageError(c)
+	}
+	if msg := middleware.AccountRestriction(&user, time.Now()); msg != "" {
+		return middleware.AccountRestrictedError(c, &user, msg)
+	}
+
+	status := 200
+	if created {
+		status = 201
+	}
+	presentUser(&user, user.ID)
+	return c.Status(status).JSON(fiber.Map{
+		"token":     utils.IssueAuthToken(user.ID.Hex(), config.AuthTokenSecret, config.AuthTokenTTL),
+		"expiresIn": int(config.AuthTokenTTL.Seconds()),
+		"user":      user,
+		// discovery and meeting requests stay unavailable until a date of birth is set
+		"dateOfBirthRequired": user.DateOfBirth == nil,
+	})
+}
+
+// POST /api/v2/auth/dev/login - sign in an existing user by email, only when ENABLE_DEV_LOGIN=true
+// and only for clients on the same machine
+func DevLogin(c *fiber.Ctx) error {
+	if !config.EnableDevLogin || !directLoopbackRequest(c) {
+		return c.Status(404).JSON(fiber.Map{"error": "Not found"})
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Email == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "email is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": body.Email}).Decode(&user); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if underage(&user, time.Now()) {
+		return underageError(c)
+	}
+	if msg := middleware.AccountRestriction(&user, time.Now()); msg != "" {
+		return middleware.AccountRestrictedError(c, &user, msg)
+	}
+	presentUser(&user, user.ID)
+	return c.Status(200).JSON(fiber.Map{
+		"token":     utils.IssueAuthToken(user.ID.Hex(), config.AuthTokenSecret, config.AuthTokenTTL),
+		"expiresIn": int(config.AuthTokenTTL.Seconds()),
+		"user":      user,
+		// discovery and meeting requests stay unavailable until a date of birth is set
+		"dateOfBirthRequired": user.DateOfBirth == nil,
+	})
+}
+
+// directLoopbackRequest reports whether the request comes straight from this machine. The
+// socket peer is used rather than c.IP(), and requests relayed by a reverse proxy running
+// on the same host (which carry forwarding headers) do not count.
+func directLoopbackRequest(c *fiber.Ctx) bool {
+	for _, header := range []string{fiber.HeaderXForwardedFor, fiber.HeaderForwarded, "X-Real-Ip"} {
+		if c.Get(header) != "" {
+			return false
+		}
+	}
+	return c.Context().RemoteIP().IsLoopback()
+}
+
+// googleUserFromCode exchanges an OAuth code and returns the matching user, creating it on first login.
+// The returned error message is safe to show to clients.
+func googleUserFromCode(code string, opts ...oauth2.AuthCodeOption) (models.User, bool, error) {
+	var user models.User
+
+	token, err := googleOauthConfig.Exchange(context.Background(), code, opts...)
+	if err != nil {
+		return user, false, errors.New("Failed to exchange token")
 	}
 
 	client := googleOauthConfig.Client(context.Background(), token)
 	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
 	if err != nil {
-		return c.Status(500).SendString("Failed to get user info")
+		return user, false, errors.New("Failed to get user info")
 	}
 	defer resp.Body.Close()
 
-	data, _ := ioutil.ReadAll(resp.Body)
+	data, _ := io.ReadAll(resp.Body)
 	var userInfo struct {
 		ID      string `json:"id"`
 		Email   string `json:"email"`
 		Name    string `json:"name"`
 		Picture string `json:"picture"`
+		// VerifiedEmail counts toward the trust score
+		VerifiedEmail bool `json:"verified_email"`
 	}
 	json.Unmarshal(data, &userInfo)
 
@@ -60,36 +178,44 @@ func GoogleCallback(c *fiber.Ctx) error {
 	defer cancel()
 
 	// Check if user already exists
-	var existingUser models.User
-	err = database.DB.Collection("users").FindOne(
-		ctx,
-		bson.M{"email": userInfo.Email},
-		options.FindOne().SetProjection(bson.M{"_id": 0}),
-	).Decode(&existingUser)
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"email": userInfo.Email}).Decode(&user)
 	if err == nil {
-		// User exists, do not update
-		return c.Status(200).SendString(fmt.Sprintf("Welcome back, %s!", existingUser.Name))
+		// User exists, do not update beyond recording a newly verified email
+		if userInfo.VerifiedEmail && !user.EmailVerified {
+			_, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+				"$set": bson.M{"email_verified": true, "updated_at": time.Now()},
+				"$inc": bson.M{"version": 1},
+			})
+			if err == nil {
+				user.EmailVerified = true
+				user.Version++
+				refreshTrustScoreLater(user.ID)
+			}
+		}
+		return user, false, nil
 	}
-	if err.Error() != "mongo: no documents in result" {
+	if err != mongo.ErrNoDocuments {
 		// Some other error
 		fmt.Println(err)
-		return c.Status(500).SendString("Failed to check user existence")
+		return user, false, errors.New("Failed to check user existence")
 	}
 
 	// User does not exist, create new user
-	newUser := models.User{
-		Name:              userInfo.Name,
-		Email:             userInfo.Email,
-		ProfilePictureURL: userInfo.Picture,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
-	}
-	_, err = database.DB.Collection("users").InsertOne(ctx, newUser)
+	user = models.User{
+		Name:          userInfo.Name,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.VerifiedEmail,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	res, err := database.DB.Collection("users").InsertOne(ctx, user)
 	if err != nil {
-		return c.Status(500).SendString("Failed to create user")
+		return user, false, errors.New("Failed to create user")
 	}
+	user.ID = res.InsertedID.(primitive.ObjectID)
+	refreshTrustScoreLater(user.ID)
 
-	return c.Status(201).SendString(fmt.Sprintf("Welcome, %s!", userInfo.Name))
+	return user, true, nil
 }
 
 func GetUsers(c *fiber.Ctx) error {
@@ -98,18 +224,20 @@ func GetUsers(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 
-	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{})
+	if viewer := viewerID(c); !viewer.IsZero() {
+		if status, msg := checkDateOfBirth(ctx, viewer, "to browse people"); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+	}
+	pipeline, err := discoveryStages(ctx, viewerID(c), time.Now())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error fetching users"})
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var user models.User
-		cursor.Decode(&user)
-		users = append(users, user)
+	if err := aggregateAll(ctx, "users", pipeline, &users); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching users"})
 	}
 
+	presentUsers(users, viewerID(c))
 	return c.JSON(users)
 }
 
@@ -119,6 +247,13 @@ func CreateUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
 
+	if user.DateOfBirth == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "dateOfBirth is required"})
+	}
+	if underage(&user, time.Now()) {
+		return underageError(c)
+	}
+
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
@@ -134,6 +269,11 @@ func CreateUser(c *fiber.Ctx) error {
 }
 
 // PATCH /users/:userId - update user info
+// The body is a JSON Merge Patch (RFC 7396) of the editable profile fields listed in
+// userPatchFields: members set to null are removed, unknown or read-only members are
+// rejected. The response is the updated user plus "changedFields".
+// The update is conditional: send If-Match with the profile's ETag (or a "version" field)
+// to get 412 Precondition Failed instead of overwriting someone else's change.
 func UpdateUserByID(c *fiber.Ctx) error {
 	userID := c.Params("userId")
 	userObjectID, err := primitive.ObjectIDFromHex(userID)
@@ -141,36 +281,70 @@ func UpdateUserByID(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
-	var updateData map[string]interface{}
-	if err := c.BodyParser(&updateData); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	var patch map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &patch); err != nil || patch == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Body must be a JSON object"})
 	}
 
-	// Remove fields that should not be updated
-	delete(updateData, "_id")
-	delete(updateData, "createdAt")
-	delete(updateData, "email") // Do not allow email change
-	updateData["updatedAt"] = time.Now()
+	var clientVersion *int64
+	if v, ok := patch["version"].(float64); ok {
+		version := int64(v)
+		clientVersion = &version
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 
-	update := bson.M{"$set": updateData}
-	res, err := database.DB.Collection("users").UpdateByID(ctx, userObjectID, update)
+	var current models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error updating user"})
+		return c.Status(500).JSON(fiber.Map{"error": "Error fetching user"})
 	}
-	if res.MatchedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	if !preconditionHolds(c, userETag(current), current.Version, clientVersion) {
+		etag := userETag(current)
+		presentUser(&current, viewerID(c))
+		return preconditionFailed(c, etag, current)
+	}
+
+	changes, fieldErrors := parseUserMergePatch(patch, &current)
+	if len(fieldErrors) > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid profile update", "fields": fieldErrors})
+	}
+	if len(changes.changed) == 0 {
+		c.Set(fiber.HeaderETag, userETag(current))
+		presentUser(&current, viewerID(c))
+		return c.Status(200).JSON(userPatchResponse{User: current, ChangedFields: changes.changed})
 	}
 
+	// the profile ETag is derived from updated_at, so it must move on every change
+	changes.set["updated_at"] = time.Now()
+	update := bson.M{"$set": changes.set, "$inc": bson.M{"version": 1}}
+	if len(changes.unset) > 0 {
+		update["$unset"] = changes.unset
+	}
+	filter := bson.M{"_id": userObjectID, "version": versionFilter(current.Version)}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedUser models.User
-	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&updatedUser)
+	err = database.DB.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedUser)
+	if err == mongo.ErrNoDocuments {
+		// someone else updated the profile between our read and write
+		if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&current); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+		etag := userETag(current)
+		presentUser(&current, viewerID(c))
+		return preconditionFailed(c, etag, current)
+	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error fetching updated user"})
+		return c.Status(500).JSON(fiber.Map{"error": "Error updating user"})
 	}
 
-	return c.Status(200).JSON(updatedUser)
+	c.Set(fiber.HeaderETag, userETag(updatedUser))
+	presentUser(&updatedUser, viewerID(c))
+	return c.Status(200).JSON(userPatchResponse{User: updatedUser, ChangedFields: changes.changed})
 }
 
 func GetUserByID(c *fiber.Ctx) error {
@@ -190,13 +364,33 @@ func GetUserByID(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
+	viewer := viewerID(c)
+	etag := userETag(user)
+	recordProfileViewLater(viewer, user.ID)
+	if !viewer.IsZero() && viewer != user.ID {
+		users := []models.User{user}
+		if err := presentMutualConnections(ctx, users, viewer); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to count mutual connections"})
+		}
+		if err := presentPresence(ctx, users, viewer); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load presence"})
+		}
+		user = users[0]
+		// the count and presence depend on the viewer and change without the user's version
+		etag = utils.StrongETag(etag, strconv.Itoa(*user.MutualConnections), presenceTag(user.Presence))
+	}
+	if notModified(c, etag) {
+		return c.SendStatus(304)
+	}
+	presentUser(&user, viewer)
 	return c.JSON(user)
 }
 
 // GetUsersByInterests returns users who have any of the provided interest IDs
 // Usage examples:
-//  - GET /api/v1/users/match-interests?interestIds=<hex>,<hex>
-//  - GET /api/v1/users/match-interests/:userId  (find matches for a specific user)
+//   - GET /api/v1/users/match-interests?interestIds=<hex>,<hex>
+//   - GET /api/v1/users/match-interests/:userId  (find matches for a specific user)
+//   - add &partial=true to also match related interests, best matches first
 func GetUsersByInterests(c *fiber.Ctx) error {
 	// allow either query param interestIds (comma separated) or path param userId
 	interestIdsParam := c.Query("interestIds", "")
@@ -245,14 +439,33 @@ func GetUsersByInterests(c *fiber.Ctx) error {
 		return c.Status(200).JSON([]models.User{})
 	}
 
+	// partial matching also counts interests in the same or a neighbouring category
+	partial := c.Query("partial") == "true"
+	weights := make(map[primitive.ObjectID]float64, len(interestObjectIDs))
+	for _, id := range interestObjectIDs {
+		weights[id] = 1
+	}
+	if partial {
+		related, err := relatedInterestWeights(ctx, interestObjectIDs)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch related interests"})
+		}
+		weights = related
+	}
+	matchIDs := make([]primitive.ObjectID, 0, len(weights))
+	for id := range weights {
+		matchIDs = append(matchIDs, id)
+	}
+
 	// find user_ids from user_interests that match any of the interest ids
-	uiCursor, err := database.DB.Collection("user_interests").Find(ctx, bson.M{"interest_id": bson.M{"$in": interestObjectIDs}})
+	uiCursor, err := database.DB.Collection("user_interests").Find(ctx, bson.M{"interest_id": bson.M{"$in": matchIDs}})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query user interests"})
 	}
 	defer uiCursor.Close(ctx)
 
 	userIDMap := make(map[string]primitive.ObjectID)
+	matches := make(map[primitive.ObjectID]*models.InterestMatch)
 	for uiCursor.Next(ctx) {
 		var ui models.UserInterest
 		uiCursor.Decode(&ui)
@@ -261,6 +474,17 @@ func GetUsersByInterests(c *fiber.Ctx) error {
 			continue
 		}
 		userIDMap[ui.UserID.Hex()] = ui.UserID
+		m := matches[ui.UserID]
+		if m == nil {
+			m = &models.InterestMatch{}
+			matches[ui.UserID] = m
+		}
+		if weights[ui.InterestID] == 1 {
+			m.Exact++
+		} else {
+			m.Partial++
+		}
+		m.Score += weights[ui.InterestID]
 	}
 
 	if len(userIDMap) == 0 {
@@ -272,23 +496,74 @@ func GetUsersByInterests(c *fiber.Ctx) error {
 		userIDs = append(userIDs, id)
 	}
 
+	// matches must fit the age preferences of the user we match for, and theirs must fit that user
+	var viewer *models.User
+	viewerObjectID := viewerID(c)
+	if routeUserId != "" {
+		viewerObjectID, _ = primitive.ObjectIDFromHex(routeUserId)
+	}
+	if !viewerObjectID.IsZero() {
+		viewer, err = FindUser(ctx, viewerObjectID)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+		}
+		if viewer.DateOfBirth == nil {
+			return c.Status(403).JSON(fiber.Map{"error": "Set your date of birth to get matches"})
+		}
+	}
+	filter := ageCompatibilityFilter(viewer, time.Now())
+	filter["_id"] = bson.M{"$in": userIDs}
+
 	// fetch user documents
-	uCursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	uCursor, err := database.DB.Collection("users").Find(ctx, filter)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch users"})
 	}
 	defer uCursor.Close(ctx)
 
-	var users []models.User
+	var candidates []models.User
+	var candidateIDs []primitive.ObjectID
 	for uCursor.Next(ctx) {
 		var u models.User
 		uCursor.Decode(&u)
-		users = append(users, u)
+		candidates = append(candidates, u)
+		candidateIDs = append(candidateIDs, u.ID)
 	}
 
+	// respect each candidate's discovery settings, blocks and account status
+	allowed, err := discoverableBy(ctx, viewerObjectID, candidateIDs, false)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to filter users"})
+	}
+	var users []models.User
+	for _, u := range candidates {
+		if allowed[u.ID] {
+			if partial {
+				u.InterestMatch = matches[u.ID]
+			}
+			users = append(users, u)
+		}
+	}
+	if partial {
+		sortByInterestMatch(users)
+	}
+
+	presentUsers(users, viewerID(c))
 	return c.Status(200).JSON(users)
 }
 
+// FindUser loads a user document, returning mongo.ErrNoDocuments if there is none
+func FindUser(ctx context.Context, userId primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userId}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func UserExists(userId primitive.ObjectID) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
@@ -358,7 +633,7 @@ func UnsetAvailableNow(c *fiber.Ctx) error {
 		"end_time":     "", // Only open-ended (currently available) entries
 	}
 	// Sort by start_time descending to get the latest
-	opts := options.FindOne().SetSort(bson.D{{"start_time", -1}})
+	opts := options.FindOne().SetSort(bson.D{{Key: "start_time", Value: -1}})
 	var avail models.Availablility
 	err = database.DB.Collection("availabilities").FindOne(ctx, filter, opts).Decode(&avail)
 	if err != nil {
@@ -512,6 +787,36 @@ func CreateMeetingRequest(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid requester ID"})
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	requester, err := FindUser(ctx, requesterObjectID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Requester not found"})
+	}
+	target, err := FindUser(ctx, targetObjectID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Target user not found"})
+	}
+	if msg := middleware.AccountRestriction(requester, time.Now()); msg != "" {
+		return middleware.AccountRestrictedError(c, requester, msg)
+	}
+	if middleware.AccountRestriction(target, time.Now()) != "" {
+		return c.Status(404).JSON(fiber.Map{"error": "Target user not found"})
+	}
+	if requester.DateOfBirth == nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Set your date of birth before requesting meetings"})
+	}
+	if blocked, err := isBlocked(ctx, requesterObjectID, targetObjectID); err != nil || blocked {
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check blocks"})
+		}
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot request a meeting with this user"})
+	}
+	if !agesCompatible(requester, target, time.Now()) {
+		return c.Status(403).JSON(fiber.Map{"error": "This user is outside your age preference, or you are outside theirs"})
+	}
+
 	meetingReq := models.MeetingRequest{
 		RequesterID:    requesterObjectID,
 		TargetUserID:   targetObjectID,
@@ -522,9 +827,6 @@ func CreateMeetingRequest(c *fiber.Ctx) error {
 		UpdatedAt:      time.Now(),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
-	defer cancel()
-
 	res, err := database.DB.Collection("meeting_requests").InsertOne(ctx, meetingReq)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create meeting request"})
@@ -560,16 +862,57 @@ func GetMeetingRequestsForUser(c *fiber.Ctx) error {
 	return c.Status(200).JSON(requests)
 }
 
+// GET /meeting-requests/:id - with ?userId= (always set on v2) only the requester and the
+// target can see it
+func GetMeetingRequest(c *fiber.Ctx) error {
+	reqObjectID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid meeting request ID"})
+	}
+	filter := bson.M{"_id": reqObjectID}
+	if userId := c.Query("userId"); userId != "" {
+		userID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+		}
+		filter["$or"] = bson.A{bson.M{"requester_id": userID}, bson.M{"target_user_id": userID}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	var meetingReq models.MeetingRequest
+	err = database.DB.Collection("meeting_requests").FindOne(ctx, filter).Decode(&meetingReq)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found"})
+	}
+
+	if notModified(c, meetingRequestETag(meetingReq)) {
+		return c.SendStatus(304)
+	}
+	return c.Status(200).JSON(meetingReq)
+}
+
 // PATCH /meeting-requests/:id (accept/reject)
+// With ?userId= (always set on v2) only the target of the request can answer it, and not
+// once it was cancelled; v1 calls without it keep answering for the target.
+// Conditional on If-Match or a "version" field, like UpdateUserByID
 func UpdateMeetingRequestStatus(c *fiber.Ctx) error {
 	reqId := c.Params("id")
 	reqObjectID, err := primitive.ObjectIDFromHex(reqId)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid meeting request ID"})
 	}
+	var userID primitive.ObjectID
+	if userId := c.Query("userId"); userId != "" {
+		if userID, err = primitive.ObjectIDFromHex(userId); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid userId"})
+		}
+	}
 
 	var body struct {
-		Status string `json:"status"` // accepted or rejected
+		Status  string `json:"status"` // accepted or rejected
+		Version *int64 `json:"version"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
@@ -581,24 +924,59 @@ func UpdateMeetingRequestStatus(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
 	defer cancel()
 
-	update := bson.M{"$set": bson.M{"status": body.Status, "updated_at": time.Now()}}
-	res, err := database.DB.Collection("meeting_requests").UpdateByID(ctx, reqObjectID, update)
+	var current models.MeetingRequest
+	err = database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqObjectID}).Decode(&current)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update meeting request status"})
-	}
-	if res.MatchedCount == 0 {
 		return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found"})
 	}
+	if !userID.IsZero() {
+		if status, msg := checkMeetingResponse(current, userID); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+	}
+	if !preconditionHolds(c, meetingRequestETag(current), current.Version, body.Version) {
+		return preconditionFailed(c, meetingRequestETag(current), current)
+	}
 
+	filter := bson.M{"_id": reqObjectID, "version": versionFilter(current.Version)}
+	set := bson.M{"status": body.Status, "updated_at": time.Now()}
+	if !userID.IsZero() {
+		filter["target_user_id"] = userID
+		filter["status"] = bson.M{"$ne": "deleted"}
+		set["responded_by"] = userID
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedReq models.MeetingRequest
-	err = database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqObjectID}).Decode(&updatedReq)
+	err = database.DB.Collection("meeting_requests").FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedReq)
+	if err == mongo.ErrNoDocuments {
+		if err := database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqObjectID}).Decode(&current); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found"})
+		}
+		return preconditionFailed(c, meetingRequestETag(current), current)
+	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch updated meeting request"})
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update meeting request status"})
 	}
+
+	c.Set(fiber.HeaderETag, meetingRequestETag(updatedReq))
 	return c.Status(200).JSON(updatedReq)
 }
 
+// checkMeetingResponse allows only the target of a meeting request to accept or reject it,
+// and only until the requester cancels it
+func checkMeetingResponse(req models.MeetingRequest, userID primitive.ObjectID) (int, string) {
+	if userID != req.TargetUserID {
+		return 403, "Only the user a meeting request was sent to can accept or reject it"
+	}
+	if req.Status == "deleted" {
+		return 409, "This meeting request was cancelled"
+	}
+	return 0, ""
+}
+
 // DELETE /meeting-requests/:id (cancel by requester)
+// Honours If-Match so a requester cannot cancel a request they have not seen accepted
 func CancelMeetingRequest(c *fiber.Ctx) error {
 	reqId := c.Params("id")
 	reqObjectID, err := primitive.ObjectIDFromHex(reqId)
@@ -617,14 +995,26 @@ func CancelMeetingRequest(c *fiber.Ctx) error {
 	defer cancel()
 
 	// Only allow update if requester matches
-	filter := bson.M{"_id": reqObjectID, "requester_id": requesterObjectID}
-	update := bson.M{"$set": bson.M{"status": "deleted", "updated_at": time.Now()}}
+	var current models.MeetingRequest
+	err = database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqObjectID, "requester_id": requesterObjectID}).Decode(&current)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found or not owned by requester"})
+	}
+	if !preconditionHolds(c, meetingRequestETag(current), current.Version, nil) {
+		return preconditionFailed(c, meetingRequestETag(current), current)
+	}
+
+	filter := bson.M{"_id": reqObjectID, "requester_id": requesterObjectID, "version": versionFilter(current.Version)}
+	update := bson.M{"$set": bson.M{"status": "deleted", "updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
 	res, err := database.DB.Collection("meeting_requests").UpdateOne(ctx, filter, update)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel meeting request"})
 	}
 	if res.MatchedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found or not owned by requester"})
+		if err := database.DB.Collection("meeting_requests").FindOne(ctx, bson.M{"_id": reqObjectID}).Decode(&current); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Meeting request not found or not owned by requester"})
+		}
+		return preconditionFailed(c, meetingRequestETag(current), current)
 	}
 	return c.Status(200).JSON(fiber.Map{"message": "Meeting request marked as deleted"})
 }
@@ -655,62 +1045,3 @@ func GetSentMeetingRequestsForUser(c *fiber.Ctx) error {
 	}
 	return c.Status(200).JSON(requests)
 }
-
-// POST /users/:userId/rate
-// Accepts JSON { "rating": <float> } where rating is typically 0-5
-// Atomically increments users_rated and updates trust_score as the new weighted average
-func RateUser(c *fiber.Ctx) error {
-	userID := c.Params("userId")
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
-	}
-
-	var body struct {
-		Rating float64 `json:"rating"`
-	}
-	if err := c.BodyParser(&body); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
-	}
-	if body.Rating < 0 || body.Rating > 5 {
-		return c.Status(400).JSON(fiber.Map{"error": "Rating must be between 0 and 5"})
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
-	defer cancel()
-
-	// Use an update pipeline so the calculation happens server-side and is atomic
-	// Handle missing fields by treating them as 0 via $ifNull to avoid null propagation
-	usersRatedCurrent := bson.M{"$ifNull": bson.A{"$users_rated", 0}}
-	trustScoreCurrent := bson.M{"$ifNull": bson.A{"$trust_score", 0}}
-	newUsersRated := bson.M{"$add": bson.A{usersRatedCurrent, 1}}
-	numerator := bson.M{"$add": bson.A{
-		bson.M{"$multiply": bson.A{trustScoreCurrent, usersRatedCurrent}},
-		body.Rating,
-	}}
-	trustScoreNew := bson.M{"$divide": bson.A{numerator, newUsersRated}}
-
-	updatePipeline := bson.A{
-		bson.M{"$set": bson.M{
-			"users_rated": newUsersRated,
-			"trust_score": trustScoreNew,
-			"updated_at":  time.Now(),
-		}},
-	}
-
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	res := database.DB.Collection("users").FindOneAndUpdate(ctx, bson.M{"_id": userObjectID}, updatePipeline, opts)
-	if res.Err() != nil {
-		if res.Err() == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
-		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user trust score"})
-	}
-
-	var updatedUser models.User
-	if err := res.Decode(&updatedUser); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to decode updated user"})
-	}
-
-	return c.Status(200).JSON(updatedUser)
-}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"fast-af/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// userPatchField is one profile field clients may edit through PATCH /users/:userId
+type userPatchField struct {
+	bson      string
+	nullable  bool // null removes the field, otherwise null is rejected
+	writeOnce bool // once set, only support can change it (admin users set-dob)
+	parse     func(value interface{}) (interface{}, error)
+	current   func(user *models.User) interface{}
+}
+
+var validGenders = map[string]bool{"female": true, "male": true, "non-binary": true, "other": true}
+
+// userPatchFields is the allow-list of editable fields, keyed by their JSON name.
+// Everything else (trustScore, verified, email, timestamps...) is read-only, including
+// profilePictureUrl, which is always the approved primary photo of the gallery.
+var userPatchFields = map[string]userPatchField{
+	"name": {
+		bson:    "name",
+		parse:   patchString(1, 80),
+		current: func(u *models.User) interface{} { return u.Name },
+	},
+	"dateOfBirth": {
+		bson:      "date_of_birth",
+		writeOnce: true,
+		parse:     parseDateOfBirth,
+		current:   func(u *models.User) interface{} { return u.DateOfBirth },
+	},
+	"agePreference": {
+		bson:     "age_preference",
+		nullable: true,
+		parse:    parseAgePreference,
+		current:  func(u *models.User) interface{} { return u.AgePreference },
+	},
+	"gender": {
+		bson:     "gender",
+		nullable: true,
+		parse: func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok || !validGenders[s] {
+				return nil, errors.New("must be one of female, male, non-binary, other")
+			}
+			return s, nil
+		},
+		current: func(u *models.User) interface{} { return u.Gender },
+	},
+	"locality": {
+		bson:     "locality",
+		nullable: true,
+		parse:    patchString(1, 100),
+		current:  func(u *models.User) interface{} { return u.Locality },
+	},
+	"bio": {
+		bson:     "bio",
+		nullable: true,
+		parse:    patchString(0, 500),
+		current:  func(u *models.User) interface{} { return u.Bio },
+	},
+}
+
+// patchString accepts a string whose trimmed length is within [min, max] characters
+func patchString(min int, max int) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		s = strings.TrimSpace(s)
+		if n := utf8.RuneCountInString(s); n < min || n > max {
+			if min > 0 {
+				return nil, errors.New("must be between " + strconv.Itoa(min) + " and " + strconv.Itoa(max) + " characters")
+			}
+			return nil, errors.New("must be at most " + strconv.Itoa(max) + " characters")
+		}
+		return s, nil
+	}
+}
+
+// userPatchResponse is the updated user with the JSON names of the fields the patch changed
+type userPatchResponse struct {
+	models.User
+	ChangedFields []string `json:"changedFields"`
+}
+
+// userMergePatch is a validated RFC 7396 merge patch of a user profile
+type userMergePatch struct {
+	set     bson.M
+	unset   bson.M
+	changed []string // JSON names of the fields whose value differs from current
+}
+
+// parseUserMergePatch validates patch against the allow-list and compares it with
+// current. Members set to null remove the field; members that would not change
+// anything are dropped. errs maps each rejected JSON field to the reason.
+func parseUserMergePatch(patch map[string]interface{}, current *models.User) (*userMergePatch, map[string]string) {
+	p := &userMergePatch{set: bson.M{}, unset: bson.M{}, changed: []string{}}
+	errs := map[string]string{}
+	for name, value := range patch {
+		if name == "version" {
+			continue // concurrency control, see preconditionHolds
+		}
+		field, ok := userPatchFields[name]
+		if !ok {
+			errs[name] = "is not an editable field"
+			continue
+		}
+		old := field.current(current)
+		if value == nil {
+			if !field.nullable {
+				errs[name] = "cannot be removed"
+				continue
+			}
+			if !reflect.ValueOf(old).IsZero() {
+				p.unset[field.bson] = ""
+				p.changed = append(p.changed, name)
+			}
+			continue
+		}
+		parsed, err := field.parse(value)
+		if err != nil {
+			errs[name] = err.Error()
+			continue
+		}
+		if !reflect.DeepEqual(parsed, old) {
+			if field.writeOnce && !reflect.ValueOf(old).IsZero() {
+				errs[name] = "is already set; contact support to change it"
+				continue
+			}
+			p.set[field.bson] = parsed
+			p.changed = append(p.changed, name)
+		}
+	}
+	sort.Strings(p.changed)
+	return p, errs
+}
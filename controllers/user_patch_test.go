@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"fast-af/models"
+)
+
+func TestDateOfBirthIsWriteOnce(t *testing.T) {
+	var user models.User
+	p, errs := parseUserMergePatch(map[string]interface{}{"dateOfBirth": "1990-05-17"}, &user)
+	if len(errs) > 0 || len(p.changed) != 1 {
+		t.Fatalf("setting a first date of birth: changed %v, errors %v", p.changed, errs)
+	}
+
+	dob := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+	user.DateOfBirth = &dob
+	if p, errs := parseUserMergePatch(map[string]interface{}{"dateOfBirth": "1990-05-17"}, &user); len(errs) > 0 || len(p.changed) != 0 {
+		t.Errorf("resending the same date of birth: changed %v, errors %v", p.changed, errs)
+	}
+	if _, errs := parseUserMergePatch(map[string]interface{}{"dateOfBirth": "1985-01-01"}, &user); errs["dateOfBirth"] == "" {
+		t.Error("changing the date of birth was accepted")
+	}
+	if _, errs := parseUserMergePatch(map[string]interface{}{"dateOfBirth": nil}, &user); errs["dateOfBirth"] == "" {
+		t.Error("removing the date of birth was accepted")
+	}
+}
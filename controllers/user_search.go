@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/middleware"
+	"fast-af/models"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxSearchPage keeps (page-1)*limit far from overflowing int64; nobody pages this deep
+	maxSearchPage = 10000
+)
+
+// userSearchSorts maps the accepted sort keys to user document fields.
+// A leading "-" on the key sorts descending.
+var userSearchSorts = map[string]string{
+	"trustScore": "trust_score",
+	"usersRated": "users_rated",
+	"age":        "date_of_birth", // sorted inversely, see buildUserSearchFilter
+	"createdAt":  "created_at",
+	"updatedAt":  "updated_at",
+	"name":       "name",
+}
+
+// userSearchParams are the typed filters of GET /users/search. Every value is parsed
+// into a Go type before it reaches the query, so user input can only ever be a
+// comparison operand, never a Mongo operator or field name.
+type userSearchParams struct {
+	AgeMin         *int
+	AgeMax         *int
+	Gender         string
+	Locality       string // case-insensitive prefix
+	Verified       *bool
+	MinTrustScore  *float64
+	MinUsersRated  *int
+	InterestIDs    []primitive.ObjectID
+	MatchAll       bool          // require every interest instead of any
+	ActiveWithin   time.Duration // last seen on a chat socket within this window
+	Sort           string        // e.g. "-trustScore"
+	Page           int64
+	Limit          int64
+	ExcludeUserIDs []primitive.ObjectID
+}
+
+// parseUserSearchParams reads and validates the search query string.
+func parseUserSearchParams(query func(key string, defaultValue ...string) string) (userSearchParams, error) {
+	p := userSearchParams{Sort: "-trustScore", Page: 1, Limit: defaultSearchLimit}
+	var err error
+
+	if p.AgeMin, err = optionalInt(query("ageMin"), "ageMin"); err != nil {
+		return p, err
+	}
+	if p.AgeMax, err = optionalInt(query("ageMax"), "ageMax"); err != nil {
+		return p, err
+	}
+	if p.AgeMin != nil && p.AgeMax != nil && *p.AgeMin > *p.AgeMax {
+		return p, errors.New("ageMin must not be greater than ageMax")
+	}
+	p.Gender = strings.TrimSpace(query("gender"))
+	p.Locality = strings.TrimSpace(query("locality"))
+	if v := query("verified"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return p, errors.New("verified must be true or false")
+		}
+		p.Verified = &b
+	}
+	if v := query("minTrustScore"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f >= 0 && f <= 5) { // also refuses NaN
+			return p, errors.New("minTrustScore must be a number between 0 and 5")
+		}
+		p.MinTrustScore = &f
+	}
+	if p.MinUsersRated, err = optionalInt(query("minUsersRated"), "minUsersRated"); err != nil {
+		return p, err
+	}
+	if v := query("interestIds"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			oid, err := primitive.ObjectIDFromHex(part)
+			if err != nil {
+				return p, errors.New("Invalid interest ID: " + part)
+			}
+			p.InterestIDs = append(p.InterestIDs, oid)
+		}
+	}
+	switch query("interestMatch", "any") {
+	case "any":
+	case "all":
+		p.MatchAll = true
+	default:
+		return p, errors.New("interestMatch must be any or all")
+	}
+	if v := query("activeWithin"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return p, errors.New("activeWithin must be a positive duration such as 72h")
+		}
+		p.ActiveWithin = d
+	}
+	if v := query("sort"); v != "" {
+		if _, ok := userSearchSorts[strings.TrimPrefix(v, "-")]; !ok {
+			return p, errors.New("Unsupported sort: " + v)
+		}
+		p.Sort = v
+	}
+	if v := query("page"); v != "" {
+		if p.Page, err = parsePage(v); err != nil {
+			return p, err
+		}
+	}
+	if v := query("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return p, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		p.Limit = n
+	}
+	return p, nil
+}
+
+// pageParams reads the page and limit query parameters shared by paged listings
+func pageParams(c *fiber.Ctx) (int64, int64, error) {
+	page, limit := int64(1), int64(defaultSearchLimit)
+	if v := c.Query("page"); v != "" {
+		n, err := parsePage(v)
+		if err != nil {
+			return 0, 0, err
+		}
+		page = n
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		limit = n
+	}
+	return page, limit, nil
+}
+
+func parsePage(v string) (int64, error) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 || n > maxSearchPage {
+		return 0, fmt.Errorf("page must be between 1 and %d", maxSearchPage)
+	}
+	return n, nil
+}
+
+func optionalInt(v string, name string) (*int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return nil, errors.New(name + " must be a non-negative integer")
+	}
+	return &n, nil
+}
+
+// buildUserSearchFilter turns search params into a users collection filter and sort.
+// userIDs restricts results to users that matched the interest filter (nil = no restriction).
+func buildUserSearchFilter(p userSearchParams, userIDs []primitive.ObjectID, now time.Time) (bson.D, bson.D) {
+	filter := bson.D{}
+
+	// ages are computed from date_of_birth, so age bounds become date of birth bounds;
+	// people without a known date of birth (or below the minimum age) never match
+	ageMin := config.MinimumAge
+	if p.AgeMin != nil && *p.AgeMin > ageMin {
+		ageMin = *p.AgeMin
+	}
+	dob := bson.D{{Key: "$lte", Value: utils.BornNoLaterThan(ageMin, now)}}
+	if p.AgeMax != nil {
+		dob = append(dob, bson.E{Key: "$gt", Value: utils.BornAfter(*p.AgeMax, now)})
+	}
+	filter = append(filter, bson.E{Key: "date_of_birth", Value: dob})
+	if p.Gender != "" {
+		// $eq keeps the value an operand even if it looks like an operator
+		filter = append(filter, bson.E{Key: "gender", Value: bson.D{{Key: "$eq", Value: p.Gender}}})
+	}
+	if p.Locality != "" {
+		filter = append(filter, bson.E{Key: "locality", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(p.Locality), Options: "i"}})
+	}
+	if p.Verified != nil {
+		filter = append(filter, bson.E{Key: "verified", Value: *p.Verified})
+	}
+	if p.MinTrustScore != nil {
+		filter = append(filter, bson.E{Key: "trust_score", Value: bson.D{{Key: "$gte", Value: *p.MinTrustScore}}})
+	}
+	if p.MinUsersRated != nil {
+		filter = append(filter, bson.E{Key: "users_rated", Value: bson.D{{Key: "$gte", Value: *p.MinUsersRated}}})
+	}
+	if p.ActiveWithin > 0 {
+		filter = append(filter, bson.E{Key: "last_seen_at", Value: bson.D{{Key: "$gte", Value: now.Add(-p.ActiveWithin)}}})
+	}
+
+	idFilter := bson.D{}
+	if userIDs != nil {
+		idFilter = append(idFilter, bson.E{Key: "$in", Value: userIDs})
+	}
+	if len(p.ExcludeUserIDs) > 0 {
+		idFilter = append(idFilter, bson.E{Key: "$nin", Value: p.ExcludeUserIDs})
+	}
+	if len(idFilter) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: idFilter})
+	}
+
+	direction := 1
+	if strings.HasPrefix(p.Sort, "-") {
+		direction = -1
+	}
+	if strings.TrimPrefix(p.Sort, "-") == "age" {
+		// older means an earlier date of birth
+		direction = -direction
+	}
+	// _id breaks ties so pages never overlap
+	sort := bson.D{
+		{Key: userSearchSorts[strings.TrimPrefix(p.Sort, "-")], Value: direction},
+		{Key: "_id", Value: direction},
+	}
+	return filter, sort
+}
+
+// usersWithInterests returns the users having any (or all) of the interest ids
+func usersWithInterests(ctx context.Context, interestIDs []primitive.ObjectID, matchAll bool) ([]primitive.ObjectID, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"interest_id": bson.M{"$in": interestIDs}}},
+		bson.M{"$group": bson.M{"_id": "$user_id", "matched": bson.M{"$addToSet": "$interest_id"}}},
+	}
+	if matchAll {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"matched": bson.M{"$size": len(interestIDs)}}})
+	}
+	cursor, err := database.DB.Collection("user_interests").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	userIDs := []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		var row struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		cursor.Decode(&row)
+		userIDs = append(userIDs, row.ID)
+	}
+	return userIDs, cursor.Err()
+}
+
+// GET /users/search?ageMin=&ageMax=&gender=&locality=&verified=&minTrustScore=&minUsersRated=
+//
+//	&interestIds=<hex>,<hex>&interestMatch=any|all&activeWithin=72h&sort=-trustScore&page=1&limit=20
+//
+// The caller and people hidden from the caller are excluded from the results.
+func SearchUsers(c *fiber.Ctx) error {
+	params, err := parseUserSearchParams(c.Query)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if caller, ok := middleware.CurrentUserID(c); ok {
+		params.ExcludeUserIDs = append(params.ExcludeUserIDs, caller)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+
+	if viewer := viewerID(c); !viewer.IsZero() {
+		if status, msg := checkDateOfBirth(ctx, viewer, "to search people"); status != 0 {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+	}
+
+	// shared interests are resolved first; the ids then narrow the users query
+	var userIDs []primitive.ObjectID
+	if len(params.InterestIDs) > 0 {
+		userIDs, err = usersWithInterests(ctx, params.InterestIDs, params.MatchAll)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to query user interests"})
+		}
+	}
+
+	// people whose visibility settings hide them from the caller are excluded in the
+	// query itself, so totals and pages stay consistent
+	now := time.Now()
+	visible, err := discoveryStages(ctx, viewerID(c), now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to search users"})
+	}
+	filter, sort := buildUserSearchFilter(params, userIDs, now)
+	pipeline := bson.A{bson.M{"$match": filter}, bson.M{"$sort": sort}}
+	pipeline = append(pipeline, visible...)
+	pipeline = append(pipeline, bson.M{"$facet": bson.M{
+		"results": bson.A{bson.M{"$skip": (params.Page - 1) * params.Limit}, bson.M{"$limit": params.Limit}},
+		"total":   bson.A{bson.M{"$count": "n"}},
+	}})
+	var facets []struct {
+		Results []models.User `bson:"results"`
+		Total   []struct {
+			N int64 `bson:"n"`
+		} `bson:"total"`
+	}
+	if err := aggregateAll(ctx, "users", pipeline, &facets); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to search users"})
+	}
+	users := []models.User{}
+	var total int64
+	if len(facets) > 0 {
+		users = append(users, facets[0].Results...)
+		if len(facets[0].Total) > 0 {
+			total = facets[0].Total[0].N
+		}
+	}
+
+	presentUsers(users, viewerID(c))
+	if err := presentPresence(ctx, users, viewerID(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load presence"})
+	}
+	return c.Status(200).JSON(fiber.Map{
+		"results": users,
+		"page":    params.Page,
+		"limit":   params.Limit,
+		"total":   total,
+	})
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// queryOf serves parseUserSearchParams from a map, like fiber's c.Query
+func queryOf(values map[string]string) func(key string, defaultValue ...string) string {
+	return func(key string, defaultValue ...string) string {
+		if v, ok := values[key]; ok {
+			return v
+		}
+		if len(defaultValue) > 0 {
+			return defaultValue[0]
+		}
+		return ""
+	}
+}
+
+// operatorKeys collects every key starting with "$" in a filter, at any depth
+func operatorKeys(v interface{}) []string {
+	var keys []string
+	switch v := v.(type) {
+	case bson.D:
+		for _, e := range v {
+			if strings.HasPrefix(e.Key, "$") {
+				keys = append(keys, e.Key)
+			}
+			keys = append(keys, operatorKeys(e.Value)...)
+		}
+	case bson.M:
+		for k, val := range v {
+			if strings.HasPrefix(k, "$") {
+				keys = append(keys, k)
+			}
+			keys = append(keys, operatorKeys(val)...)
+		}
+	case bson.A:
+		for _, val := range v {
+			keys = append(keys, operatorKeys(val)...)
+		}
+	}
+	return keys
+}
+
+func TestUserSearchFilterKeepsInputAsOperands(t *testing.T) {
+	p, err := parseUserSearchParams(queryOf(map[string]string{
+		"gender":   `{"$ne": null}`,
+		"locality": `.*$where(1)[`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, _ := buildUserSearchFilter(p, nil, time.Now())
+
+	allowed := map[string]bool{"$lte": true, "$eq": true}
+	for _, key := range operatorKeys(filter) {
+		if !allowed[key] {
+			t.Errorf("unexpected operator %s in %v", key, filter)
+		}
+	}
+	for _, e := range filter {
+		switch e.Key {
+		case "gender":
+			if got := e.Value.(bson.D)[0].Value; got != `{"$ne": null}` {
+				t.Errorf("gender operand is %v, want the raw string", got)
+			}
+		case "locality":
+			re := e.Value.(primitive.Regex)
+			if re.Pattern != `^\.\*\$where\(1\)\[` {
+				t.Errorf("locality pattern is %q, want the quoted prefix", re.Pattern)
+			}
+		}
+	}
+}
+
+func TestUserSearchParamsRejectInjection(t *testing.T) {
+	tests := map[string]string{
+		"sort":          "$where",
+		"ageMin":        `{"$gt": 0}`,
+		"minTrustScore": "NaN",
+		"verified":      `{"$exists": true}`,
+		"interestIds":   `{"$ne": ""}`,
+		"interestMatch": "$all",
+		"activeWithin":  "-1h",
+		"limit":         "1e9",
+	}
+	for key, value := range tests {
+		if _, err := parseUserSearchParams(queryOf(map[string]string{key: value})); err == nil {
+			t.Errorf("%s=%s was accepted", key, value)
+		}
+	}
+}
+
+func TestUserSearchPageIsBounded(t *testing.T) {
+	for _, page := range []string{"0", "-1", strconv.Itoa(maxSearchPage + 1), strconv.FormatInt(math.MaxInt64, 10)} {
+		if _, err := parseUserSearchParams(queryOf(map[string]string{"page": page})); err == nil {
+			t.Errorf("page=%s was accepted", page)
+		}
+	}
+	p, err := parseUserSearchParams(queryOf(map[string]string{"page": strconv.Itoa(maxSearchPage), "limit": strconv.Itoa(maxSearchLimit)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skip := (p.Page - 1) * p.Limit; skip < 0 {
+		t.Errorf("skip overflowed: %d", skip)
+	}
+}
+
+func TestUserSearchActiveWithinUsesLastSeen(t *testing.T) {
+	now := time.Now()
+	p, err := parseUserSearchParams(queryOf(map[string]string{"activeWithin": "72h"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, _ := buildUserSearchFilter(p, nil, now)
+	for _, e := range filter {
+		if e.Key == "updated_at" {
+			t.Errorf("activeWithin filters on updated_at")
+		}
+		if e.Key == "last_seen_at" {
+			if got := e.Value.(bson.D)[0].Value; got != now.Add(-72*time.Hour) {
+				t.Errorf("last_seen_at bound is %v", got)
+			}
+			return
+		}
+	}
+	t.Errorf("no last_seen_at filter in %v", filter)
+}
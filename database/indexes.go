@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fast-af/config"
+)
+
+// EnsureIndexes creates the indexes the application relies on. Creating an index
+// that already exists with the same options is a no-op, so this runs on every start.
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// people search (GET /users/search): equality filters first, then the range/sort
+	// field, so the common combinations are served by an index prefix
+	ensure(ctx, "users", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "gender", Value: 1}, {Key: "date_of_birth", Value: 1}, {Key: "trust_score", Value: -1}}},
+		{Keys: bson.D{{Key: "locality", Value: 1}, {Key: "trust_score", Value: -1}}},
+		{Keys: bson.D{{Key: "verified", Value: 1}, {Key: "trust_score", Value: -1}}},
+		{Keys: bson.D{{Key: "trust_score", Value: -1}, {Key: "users_rated", Value: -1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: -1}}},
+		{Keys: bson.D{{Key: "last_seen_at", Value: -1}}},
+		// photo moderation queue
+		{Keys: bson.D{{Key: "photos.moderation_status", Value: 1}}},
+		// suspended and banned users are excluded from every listing
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "suspended_until", Value: 1}}},
+	})
+	// shared-interest lookups go interest -> users, profile pages go user -> interests
+	ensure(ctx, "user_interests", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "interest_id", Value: 1}, {Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "interest_id", Value: 1}}},
+	})
+
+	// one rating per rater and user; reviews are listed per rated user
+	ensure(ctx, "ratings", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "rater_id", Value: 1}, {Key: "ratee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ratee_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+
+	// blocks are looked up from both sides
+	ensure(ctx, "user_blocks", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "blocker_id", Value: 1}, {Key: "blocked_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "blocked_id", Value: 1}}},
+	})
+
+	// one document per pair of users; connections and requests are listed per user
+	ensure(ctx, "connections", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "pair_key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_ids", Value: 1}, {Key: "status", Value: 1}, {Key: "accepted_at", Value: -1}}},
+		{Keys: bson.D{{Key: "addressee_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "requester_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	// people who were accepted onto the same slot, for suggestions
+	ensure(ctx, "meeting_requests", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "availability_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+
+	// moderation queue (status, oldest first), per-user history and duplicate report checks
+	ensure(ctx, "reports", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "assignee_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "reported_user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "reporter_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}}},
+	})
+	ensure(ctx, "moderation_events", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "report_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "moderator_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "action", Value: 1}}},
+	})
+	// category tree: slugs for lookups, siblings in display order, subtrees by ancestor
+	ensure(ctx, "interest_categories", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "order", Value: 1}}},
+		{Keys: bson.D{{Key: "path", Value: 1}}},
+	})
+	ensure(ctx, "interests", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category_id", Value: 1}}},
+	})
+
+	// one view per viewer, profile and day; expired after the retention period
+	ensure(ctx, "profile_views", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "viewed_id", Value: 1}, {Key: "viewer_id", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "viewed_id", Value: 1}, {Key: "day", Value: 1}}},
+		{Keys: bson.D{{Key: "viewer_id", Value: 1}}},
+	})
+	ensureTTL(ctx, "profile_views", "viewed_at", config.ProfileViewRetention)
+	ensure(ctx, "user_warnings", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	ensure(ctx, "chat_reads", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "chat_window_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+
+	ensure(ctx, "idempotency_keys", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}, {Key: "caller", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	ensureTTL(ctx, "idempotency_keys", "created_at", config.IdempotencyKeyTTL)
+}
+
+func ensure(ctx context.Context, collection string, indexes []mongo.IndexModel) {
+	if _, err := DB.Collection(collection).Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Fatalf("Failed to create indexes on %s: %v", collection, err)
+	}
+}
+
+// ensureTTL keeps a TTL index on field that expires documents ttl after its value. Creating
+// the index again with another expiry fails, so a changed ttl is applied with collMod.
+func ensureTTL(ctx context.Context, collection string, field string, ttl time.Duration) {
+	seconds := int64(ttl.Seconds())
+	cursor, err := DB.Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list indexes on %s: %v", collection, err)
+	}
+	var indexes []struct {
+		Name               string `bson:"name"`
+		Key                bson.D `bson:"key"`
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		log.Fatalf("Failed to list indexes on %s: %v", collection, err)
+	}
+	for _, index := range indexes {
+		if len(index.Key) != 1 || index.Key[0].Key != field || index.ExpireAfterSeconds == nil {
+			continue
+		}
+		if *index.ExpireAfterSeconds != seconds {
+			cmd := bson.D{{Key: "collMod", Value: collection}, {Key: "index", Value: bson.M{"name": index.Name, "expireAfterSeconds": seconds}}}
+			if err := DB.RunCommand(ctx, cmd).Err(); err != nil {
+				log.Fatalf("Failed to change the expiry of %s.%s: %v", collection, field, err)
+			}
+			log.Printf("Changed the expiry of %s.%s to %s", collection, field, ttl)
+		}
+		return
+	}
+	ensure(ctx, collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: field, Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(seconds))},
+	})
+}
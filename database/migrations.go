@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fast-af/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrate brings documents written by older versions up to the current schema.
+// Every step only touches documents still in the old shape, so this runs on every start.
+func Migrate() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	migrateAgeToDateOfBirth(ctx, time.Now())
+	migrateAnonymousRatings(ctx)
+	migrateInterestCategories(ctx)
+}
+
+// migrateAgeToDateOfBirth replaces the stored "age" with an estimated date of birth,
+// placed half a year before the last birthday consistent with that age so the
+// computed age matches the stored one. Support can correct it with admin users set-dob.
+func migrateAgeToDateOfBirth(ctx context.Context, now time.Time) {
+	filter := bson.M{"age": bson.M{"$exists": true}}
+	cursor, err := DB.Collection("users").Find(ctx, filter)
+	if err != nil {
+		log.Fatalf("Failed to migrate user ages: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var updates []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var user struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			Age         int                `bson:"age"`
+			DateOfBirth *time.Time         `bson:"date_of_birth"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		update := bson.M{"$unset": bson.M{"age": ""}}
+		if user.DateOfBirth == nil && user.Age > 0 {
+			update["$set"] = bson.M{"date_of_birth": today.AddDate(-user.Age, -6, 0)}
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": user.ID}).SetUpdate(update))
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if _, err := DB.Collection("users").BulkWrite(ctx, updates); err != nil {
+		log.Fatalf("Failed to migrate user ages: %v", err)
+	}
+	log.Printf("Migrated %d users from age to date_of_birth", len(updates))
+}
+
+// migrateAnonymousRatings keeps the running average older versions stored on rated users
+// without per-rater ratings: the score and count move to legacy_ratings so recomputing
+// from the ratings collection preserves them.
+func migrateAnonymousRatings(ctx context.Context) {
+	filter := bson.M{"users_rated": bson.M{"$gt": 0}, "legacy_ratings": bson.M{"$exists": false}}
+	cursor, err := DB.Collection("users").Find(ctx, filter)
+	if err != nil {
+		log.Fatalf("Failed to migrate anonymous ratings: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var updates []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var user struct {
+			ID         primitive.ObjectID `bson:"_id"`
+			TrustScore float64            `bson:"trust_score"`
+			UsersRated int                `bson:"users_rated"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		// users rated since the upgrade have rating documents and are already in shape
+		rated, err := DB.Collection("ratings").CountDocuments(ctx, bson.M{"ratee_id": user.ID})
+		if err != nil {
+			log.Fatalf("Failed to migrate anonymous ratings: %v", err)
+		}
+		if rated > 0 {
+			continue
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": user.ID}).SetUpdate(bson.M{
+			"$set": bson.M{"legacy_ratings": bson.M{"count": user.UsersRated, "total": user.TrustScore * float64(user.UsersRated)}},
+		}))
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if _, err := DB.Collection("users").BulkWrite(ctx, updates); err != nil {
+		log.Fatalf("Failed to migrate anonymous ratings: %v", err)
+	}
+	log.Printf("Migrated anonymous ratings of %d users to legacy_ratings", len(updates))
+}
+
+// migrateInterestCategories files interests that only have a free-text category under a
+// top-level node of the category tree, one per slug, so "Sports" and "sports" end up
+// together. Moderators can then move nodes and interests with the admin CLI.
+func migrateInterestCategories(ctx context.Context) {
+	interests := DB.Collection("interests")
+	names, err := interests.Distinct(ctx, "category", bson.M{"category_id": bson.M{"$exists": false}, "category": bson.M{"$ne": ""}})
+	if err != nil {
+		log.Fatalf("Failed to migrate interest categories: %v", err)
+	}
+	migrated := int64(0)
+	for _, v := range names {
+		name, ok := v.(string)
+		slug := utils.Slugify(name)
+		if !ok || slug == "" {
+			continue
+		}
+		var category struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err := DB.Collection("interest_categories").FindOneAndUpdate(ctx, bson.M{"slug": slug}, bson.M{
+			"$setOnInsert": bson.M{"name": name, "slug": slug, "path": bson.A{}, "order": 0},
+		}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&category)
+		if err != nil {
+			log.Fatalf("Failed to migrate interest categories: %v", err)
+		}
+		res, err := interests.UpdateMany(ctx, bson.M{"category": name, "category_id": bson.M{"$exists": false}}, bson.M{
+			"$set": bson.M{"category_id": category.ID},
+		})
+		if err != nil {
+			log.Fatalf("Failed to migrate interest categories: %v", err)
+		}
+		migrated += res.ModifiedCount
+	}
+	if migrated > 0 {
+		log.Printf("Filed %d interests under the interest category tree", migrated)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fast-af/config"
+	"fast-af/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CurrentUserKey is the fiber Locals key holding the authenticated user's ObjectID
+const CurrentUserKey = "currentUserId"
+
+// RequireAuth rejects requests without a valid bearer token and stores the caller's id in Locals.
+// WebSocket handshakes cannot set headers from browsers, so an access_token query param is also accepted.
+// Tokens of deleted, suspended or banned users are refused.
+func RequireAuth(c *fiber.Ctx) error {
+	token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if token == "" {
+		token = c.Query("access_token")
+	}
+	if token == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Missing auth token"})
+	}
+
+	userID, err := utils.ParseAuthToken(token, config.AuthTokenSecret)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired auth token"})
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired auth token"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	user, err := AccountStatus(ctx, userObjectID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired auth token"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check account status"})
+	}
+	if msg := AccountRestriction(&user, time.Now()); msg != "" {
+		return AccountRestrictedError(c, &user, msg)
+	}
+
+	c.Locals(CurrentUserKey, userObjectID)
+	return c.Next()
+}
+
+// CurrentUserID returns the authenticated caller, if RequireAuth ran for this request.
+func CurrentUserID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	oid, ok := c.Locals(CurrentUserKey).(primitive.ObjectID)
+	return oid, ok
+}
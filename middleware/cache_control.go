@@ -0,0 +1,24 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// Cache-Control policies used by the routes
+const (
+	// CachePrivateRevalidate lets clients keep a copy but forces an ETag round trip on every use
+	CachePrivateRevalidate = "private, no-cache"
+	// CachePublicShort suits shared, rarely changing data such as the interest catalog
+	CachePublicShort = "public, max-age=60, must-revalidate"
+)
+
+// CacheControl sets the Cache-Control header for successful GET responses of a route.
+func CacheControl(policy string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if status := c.Response().StatusCode(); status == 200 || status == 304 {
+			c.Set(fiber.HeaderCacheControl, policy)
+		} else {
+			c.Set(fiber.HeaderCacheControl, "no-store")
+		}
+		return err
+	}
+}
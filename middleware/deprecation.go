@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RouteUsage is the number of calls a deprecated route has served since startup
+type RouteUsage struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Calls  int64  `json:"calls"`
+}
+
+// "METHOD /route/:pattern" -> *atomic.Int64
+var deprecatedUsage sync.Map
+
+// Deprecated marks every response of the group it is mounted on with Deprecation, Sunset
+// and a successor-version Link header, and counts calls per matched route.
+func Deprecated(deprecatedAt time.Time, sunset time.Time, successor string) fiber.Handler {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	sunsetValue := sunset.UTC().Format(http.TimeFormat)
+	link := "<" + successor + `>; rel="successor-version"`
+
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", deprecation)
+		c.Set("Sunset", sunsetValue)
+		c.Set(fiber.HeaderLink, link)
+
+		err := c.Next()
+
+		// after Next the context points at the route that actually handled the request
+		route := c.Route()
+		key := route.Method + " " + route.Path
+		counter, _ := deprecatedUsage.LoadOrStore(key, new(atomic.Int64))
+		counter.(*atomic.Int64).Add(1)
+		return err
+	}
+}
+
+// DeprecatedUsage returns the per-route call counters, busiest first.
+func DeprecatedUsage() []RouteUsage {
+	var usage []RouteUsage
+	deprecatedUsage.Range(func(k, v interface{}) bool {
+		method, path, _ := strings.Cut(k.(string), " ")
+		usage = append(usage, RouteUsage{Method: method, Path: path, Calls: v.(*atomic.Int64).Load()})
+		return true
+	})
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Calls > usage[j].Calls })
+	return usage
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const idempotencyHandledKey = "idempotencyHandled"
+
+// idempotencyClaimTimeout is how long a key stays "in progress" before a retry may take it
+// over, in case the request holding it never finished (crash, restart)
+const idempotencyClaimTimeout = time.Minute
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to retry.
+// The first request with a key stores its response; a retry with the same key and
+// payload replays it, and the same key with a different payload is rejected with 422.
+// Keys are scoped to the authenticated caller and expire after config.IdempotencyKeyTTL.
+// v1 has no caller identity, so there a key only matches an identical request from the
+// same address: clients sharing an address (NAT) never see each other's responses.
+func Idempotency(c *fiber.Ctx) error {
+	key := c.Get("Idempotency-Key")
+	if c.Method() != fiber.MethodPost || key == "" || c.Locals(idempotencyHandledKey) != nil {
+		return c.Next()
+	}
+	// routing may be restarted (see the /users/me alias); only handle the key once
+	c.Locals(idempotencyHandledKey, true)
+
+	if len(key) > 255 {
+		return c.Status(400).JSON(fiber.Map{"error": "Idempotency-Key must be at most 255 characters"})
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.Path() + "?" + string(c.Request().URI().QueryString()) + "\n"))
+	hash.Write(c.Body())
+	requestHash := hex.EncodeToString(hash.Sum(nil))
+	caller := "ip:" + c.IP() + ":" + requestHash
+	if userID, ok := CurrentUserID(c); ok {
+		caller = userID.Hex()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer cancel()
+	keys := database.DB.Collection("idempotency_keys")
+	filter := bson.M{"key": key, "caller": caller}
+
+	now := time.Now()
+	var stored models.IdempotencyKey
+	err := keys.FindOne(ctx, filter).Decode(&stored)
+	switch {
+	case err == nil && !stored.Completed && now.Sub(stored.CreatedAt) > idempotencyClaimTimeout:
+		// the request holding the key never finished; take the key over
+		res, err := keys.UpdateOne(ctx, bson.M{"_id": stored.ID, "completed": false, "created_at": stored.CreatedAt},
+			bson.M{"$set": bson.M{"request_hash": requestHash, "created_at": now}})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error storing idempotency key"})
+		}
+		if res.ModifiedCount == 0 {
+			return c.Status(409).JSON(fiber.Map{"error": "A request with this Idempotency-Key is already in progress"})
+		}
+	case err == nil:
+		return replayIdempotent(c, stored, requestHash)
+	case err != mongo.ErrNoDocuments:
+		return c.Status(500).JSON(fiber.Map{"error": "Error checking idempotency key"})
+	default:
+		// claim the key before running the handler; the unique index settles concurrent retries
+		_, err = keys.InsertOne(ctx, models.IdempotencyKey{
+			Key:         key,
+			Caller:      caller,
+			RequestHash: requestHash,
+			CreatedAt:   now,
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			if err := keys.FindOne(ctx, filter).Decode(&stored); err == nil {
+				return replayIdempotent(c, stored, requestHash)
+			}
+			return c.Status(409).JSON(fiber.Map{"error": "A request with this Idempotency-Key is already in progress"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Error storing idempotency key"})
+		}
+	}
+
+	handlerErr := c.Next()
+
+	// the handler may have outlived ctx
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+	defer saveCancel()
+	status := c.Response().StatusCode()
+	if handlerErr != nil || status >= 500 {
+		// server-side failures are not final: release the key so the client can retry
+		keys.DeleteOne(saveCtx, filter)
+		return handlerErr
+	}
+	keys.UpdateOne(saveCtx, filter, bson.M{"$set": bson.M{
+		"completed":    true,
+		"status_code":  status,
+		"content_type": string(c.Response().Header.ContentType()),
+		"body":         append([]byte(nil), c.Response().Body()...),
+	}})
+	return nil
+}
+
+func replayIdempotent(c *fiber.Ctx, stored models.IdempotencyKey, requestHash string) error {
+	if stored.RequestHash != requestHash {
+		return c.Status(422).JSON(fiber.Map{"error": "Idempotency-Key was already used with a different request payload"})
+	}
+	if !stored.Completed {
+		return c.Status(409).JSON(fiber.Map{"error": "A request with this Idempotency-Key is already in progress"})
+	}
+	c.Set("Idempotent-Replayed", "true")
+	c.Set(fiber.HeaderContentType, stored.ContentType)
+	return c.Status(stored.StatusCode).Send(stored.Body)
+}
@@ -0,0 +1,12 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// RequireIfMatch rejects updates that do not say which version they were based on,
+// so clients cannot silently overwrite each other's changes.
+func RequireIfMatch(c *fiber.Ctx) error {
+	if c.Get(fiber.HeaderIfMatch) == "" {
+		return c.Status(428).JSON(fiber.Map{"error": "If-Match header with the resource's ETag is required"})
+	}
+	return c.Next()
+}
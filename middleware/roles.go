@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"fast-af/config"
+	"fast-af/database"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RequireRole lets the request through only when the caller holds one of roles.
+// Roles are read from the user document on every request so revoking one takes effect
+// immediately. Must run after RequireAuth.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, ok := CurrentUserID(c)
+		if !ok {
+			return c.Status(401).JSON(fiber.Map{"error": "Missing auth token"})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DefaultDBContextTimeout)*time.Second)
+		defer cancel()
+
+		var user models.User
+		opts := options.FindOne().SetProjection(bson.M{"role": 1})
+		if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": caller}, opts).Decode(&user); err != nil {
+			return c.Status(403).JSON(fiber.Map{"error": "Insufficient permissions"})
+		}
+		for _, role := range roles {
+			if user.Role == role {
+				return c.Next()
+			}
+		}
+		return c.Status(403).JSON(fiber.Map{"error": "Insufficient permissions"})
+	}
+}
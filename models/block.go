@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserBlock records that BlockerID blocked BlockedID. Blocks work both ways: neither
+// user can find, message or request a meeting with the other.
+type UserBlock struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	BlockerID primitive.ObjectID `bson:"blocker_id" json:"blockerId"`
+	BlockedID primitive.ObjectID `bson:"blocked_id" json:"blockedId"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
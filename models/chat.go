@@ -11,6 +11,7 @@ type ChatWindow struct {
 	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
 	ParticipantIDs []primitive.ObjectID `bson:"participant_ids" json:"participantIds"`
 	IsGroup        bool                 `bson:"is_group" json:"isGroup"`
+	Version        int64                `bson:"version" json:"version"`
 	CreatedAt      time.Time            `bson:"created_at" json:"createdAt"`
 	UpdatedAt      time.Time            `bson:"updated_at" json:"updatedAt"`
 }
@@ -23,8 +24,16 @@ type Chat struct {
 	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
 }
 
+// ChatRead records when a user last read a chat window
+type ChatRead struct {
+	ChatWindowID primitive.ObjectID `bson:"chat_window_id" json:"chatWindowId"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"userId"`
+	ReadAt       time.Time          `bson:"read_at" json:"readAt"`
+}
+
 type ChatRestriction struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ChatWindowID    primitive.ObjectID `bson:"chat_window_id" json:"chatWindowId"`
 	RestrictionType string             `bson:"restriction_type" json:"restrictionType"`
 	RestrictedBy    primitive.ObjectID `bson:"restricted_by" json:"restrictedBy"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Connection links two users. It starts as a pending request from RequesterID to
+// AddresseeID and becomes mutual once accepted. There is one document per pair of
+// users, whoever asked first.
+type Connection struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	PairKey     string               `bson:"pair_key" json:"-"`       // both user ids, smallest first
+	UserIDs     []primitive.ObjectID `bson:"user_ids" json:"userIds"` // both users, smallest first
+	RequesterID primitive.ObjectID   `bson:"requester_id" json:"requesterId"`
+	AddresseeID primitive.ObjectID   `bson:"addressee_id" json:"addresseeId"`
+	Message     string               `bson:"message,omitempty" json:"message,omitempty"`
+	Status      string               `bson:"status" json:"status"` // pending, accepted, declined
+	Version     int64                `bson:"version" json:"version"`
+	CreatedAt   time.Time            `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updatedAt"`
+	AcceptedAt  *time.Time           `bson:"accepted_at,omitempty" json:"acceptedAt,omitempty"`
+}
+
+const (
+	ConnectionPending  = "pending"
+	ConnectionAccepted = "accepted"
+	ConnectionDeclined = "declined"
+)
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyKey records the outcome of a POST sent with an Idempotency-Key header,
+// so that retries of the same request replay the original response.
+type IdempotencyKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Key         string             `bson:"key" json:"key"`
+	Caller      string             `bson:"caller" json:"caller"` // user id, or "ip:<addr>:<request hash>" for unauthenticated routes
+	RequestHash string             `bson:"request_hash" json:"requestHash"`
+	Completed   bool               `bson:"completed" json:"completed"`
+	StatusCode  int                `bson:"status_code" json:"statusCode"`
+	ContentType string             `bson:"content_type" json:"contentType"`
+	Body        []byte             `bson:"body" json:"body"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"` // TTL indexed; reset when a stale claim is taken over
+}
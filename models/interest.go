@@ -5,9 +5,22 @@ import (
 )
 
 type Interest struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name            string             `bson:"name" json:"name"`
-	Category        string             `bson:"category" json:"category"`
-	Description     string             `bson:"description" json:"description"`
-	CreatedByUserID primitive.ObjectID `bson:"created_by_user_id" json:"createdByUserId"`
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	Name            string              `bson:"name" json:"name"`
+	CategoryID      *primitive.ObjectID `bson:"category_id,omitempty" json:"categoryId,omitempty"` // node of the category tree
+	Category        string              `bson:"category" json:"category"`                          // name of that node; free text before the tree existed
+	Description     string              `bson:"description" json:"description"`
+	CreatedByUserID primitive.ObjectID  `bson:"created_by_user_id" json:"createdByUserId"`
+}
+
+// InterestCategory is a node of the interest category tree, e.g. Sports > Climbing.
+// Interests belong to one node; interests in the same node or in a parent and child
+// node are related, which matching counts as a partial match.
+type InterestCategory struct {
+	ID       primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name     string               `bson:"name" json:"name"`
+	Slug     string               `bson:"slug" json:"slug"` // unique, lowercase with dashes
+	ParentID *primitive.ObjectID  `bson:"parent_id,omitempty" json:"parentId,omitempty"`
+	Path     []primitive.ObjectID `bson:"path" json:"-"`      // ancestors from the root, for subtree queries
+	Order    int                  `bson:"order" json:"order"` // display order among siblings
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StoredPhoto points at an uploaded image and its thumbnail in storage. Keys are never
+// exposed; responses carry signed, expiring URLs instead (see controllers.presentUser).
+type StoredPhoto struct {
+	Key          string    `bson:"key" json:"-"`
+	ThumbnailKey string    `bson:"thumbnail_key" json:"-"`
+	Width        int       `bson:"width" json:"width"`
+	Height       int       `bson:"height" json:"height"`
+	UploadedAt   time.Time `bson:"uploaded_at" json:"uploadedAt"`
+	URL          string    `bson:"-" json:"url,omitempty"`
+	ThumbnailURL string    `bson:"-" json:"thumbnailUrl,omitempty"`
+}
+
+// Photo is one picture in a user's gallery. The gallery is stored in order on the user
+// document; the first approved photo is the user's primary picture.
+type Photo struct {
+	ID               primitive.ObjectID `bson:"_id" json:"id"`
+	StoredPhoto      `bson:",inline"`
+	ModerationStatus string `bson:"moderation_status" json:"moderationStatus"` // pending, approved, rejected
+}
+
+const (
+	PhotoPending  = "pending"
+	PhotoApproved = "approved"
+	PhotoRejected = "rejected"
+)
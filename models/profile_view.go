@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProfileView records that ViewerID looked at ViewedID's profile. There is one document
+// per viewer, profile and day, so repeated views on the same day count once; documents
+// expire once the retention period (config.ProfileViewRetention) has passed.
+type ProfileView struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ViewedID     primitive.ObjectID `bson:"viewed_id" json:"viewedId"`
+	ViewerID     primitive.ObjectID `bson:"viewer_id" json:"viewerId"`
+	Day          string             `bson:"day" json:"day"`            // YYYY-MM-DD in UTC
+	ViewedAt     time.Time          `bson:"viewed_at" json:"viewedAt"` // first view that day, drives expiry
+	LastViewedAt time.Time          `bson:"last_viewed_at" json:"lastViewedAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rating is one user's rating of another, with an optional written review, given after
+// a meeting between them. A rater has at most one rating per user; rating again after
+// a later meeting replaces it.
+type Rating struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RaterID          primitive.ObjectID `bson:"rater_id" json:"raterId"`
+	RateeID          primitive.ObjectID `bson:"ratee_id" json:"userId"`
+	MeetingRequestID primitive.ObjectID `bson:"meeting_request_id,omitempty" json:"meetingRequestId,omitempty"` // the meeting the current score is for
+	Score            float64            `bson:"score" json:"rating"`
+	Review           string             `bson:"review,omitempty" json:"review,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// RatingTally sums ratings given before they were stored per rater
+type RatingTally struct {
+	Count int     `bson:"count" json:"count"`
+	Total float64 `bson:"total" json:"total"`
+}
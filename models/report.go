@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Report is a user's complaint about another user, one of their chat messages or a
+// meeting request they sent. Evidence is copied when the report is filed, so the
+// reported content can be reviewed even after it is edited or deleted.
+type Report struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	ReporterID     primitive.ObjectID  `bson:"reporter_id" json:"reporterId"`
+	ReportedUserID primitive.ObjectID  `bson:"reported_user_id" json:"reportedUserId"`
+	TargetType     string              `bson:"target_type" json:"targetType"` // user, message, meeting_request
+	TargetID       primitive.ObjectID  `bson:"target_id" json:"targetId"`
+	Reason         string              `bson:"reason" json:"reason"`
+	Details        string              `bson:"details,omitempty" json:"details,omitempty"`
+	Evidence       ReportEvidence      `bson:"evidence" json:"evidence"`
+	Status         string              `bson:"status" json:"status"` // open, in_review, resolved, dismissed
+	AssigneeID     *primitive.ObjectID `bson:"assignee_id,omitempty" json:"assigneeId,omitempty"`
+	Resolution     *ReportResolution   `bson:"resolution,omitempty" json:"resolution,omitempty"`
+	Version        int64               `bson:"version" json:"version"`
+	CreatedAt      time.Time           `bson:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time           `bson:"updated_at" json:"updatedAt"`
+}
+
+// ReportEvidence is a snapshot of the reported content taken when the report was filed
+type ReportEvidence struct {
+	UserName       string              `bson:"user_name" json:"userName"`
+	UserBio        string              `bson:"user_bio,omitempty" json:"userBio,omitempty"`
+	ChatWindowID   *primitive.ObjectID `bson:"chat_window_id,omitempty" json:"chatWindowId,omitempty"`
+	MessageText    string              `bson:"message_text,omitempty" json:"messageText,omitempty"`
+	MessageSentAt  *time.Time          `bson:"message_sent_at,omitempty" json:"messageSentAt,omitempty"`
+	MeetingMessage string              `bson:"meeting_message,omitempty" json:"meetingMessage,omitempty"`
+	MeetingStatus  string              `bson:"meeting_status,omitempty" json:"meetingStatus,omitempty"`
+	CapturedAt     time.Time           `bson:"captured_at" json:"capturedAt"`
+}
+
+// ReportResolution is the moderator's decision on a report
+type ReportResolution struct {
+	Action         string             `bson:"action" json:"action"` // warn, suspend, ban, dismiss
+	Note           string             `bson:"note,omitempty" json:"note,omitempty"`
+	SuspendedUntil *time.Time         `bson:"suspended_until,omitempty" json:"suspendedUntil,omitempty"`
+	ModeratorID    primitive.ObjectID `bson:"moderator_id" json:"moderatorId"`
+	ResolvedAt     time.Time          `bson:"resolved_at" json:"resolvedAt"`
+}
+
+// ModerationEvent is one entry of the moderation audit trail
+type ModerationEvent struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	ReportID    primitive.ObjectID  `bson:"report_id" json:"reportId"`
+	ModeratorID primitive.ObjectID  `bson:"moderator_id" json:"moderatorId"`
+	Action      string              `bson:"action" json:"action"`                            // assign, unassign, warn, suspend, ban, dismiss
+	SubjectID   *primitive.ObjectID `bson:"subject_id,omitempty" json:"subjectId,omitempty"` // the user acted upon or assigned
+	Note        string              `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"createdAt"`
+}
+
+// UserWarning is a formal warning a moderator issued, visible to the warned user
+type UserWarning struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"userId"`
+	ReportID    primitive.ObjectID `bson:"report_id" json:"reportId"`
+	Reason      string             `bson:"reason" json:"reason"`
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+	ModeratorID primitive.ObjectID `bson:"moderator_id" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+const (
+	ReportTargetUser           = "user"
+	ReportTargetMessage        = "message"
+	ReportTargetMeetingRequest = "meeting_request"
+
+	ReportOpen      = "open"
+	ReportInReview  = "in_review"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+
+	// ReportReasonNoShow is for meeting requests the reported user didn't turn up to
+	ReportReasonNoShow = "no_show"
+
+	ModerationAssign   = "assign"
+	ModerationUnassign = "unassign"
+	ModerationWarn     = "warn"
+	ModerationSuspend  = "suspend"
+	ModerationBan      = "ban"
+	ModerationDismiss  = "dismiss"
+)
+
+// ReportReasons is the reason taxonomy reports are filed under
+var ReportReasons = []string{
+	"harassment",
+	"hate_speech",
+	"spam",
+	"scam",
+	"fake_profile",
+	"inappropriate_content",
+	"threats",
+	ReportReasonNoShow,
+	"underage",
+	"other",
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserSettings holds a user's preferences, one document per user keyed by the user id.
+// Users without a stored document use the server defaults (see controllers.defaultUserSettings).
+type UserSettings struct {
+	UserID        primitive.ObjectID   `bson:"_id" json:"userId"`
+	Discovery     DiscoverySettings    `bson:"discovery" json:"discovery"`
+	Notifications NotificationSettings `bson:"notifications" json:"notifications"`
+	Chat          ChatSettings         `bson:"chat" json:"chat"`
+	Locale        string               `bson:"locale" json:"locale"`     // BCP 47 tag, e.g. en-GB
+	Timezone      string               `bson:"timezone" json:"timezone"` // IANA name, e.g. Europe/London
+	Version       int64                `bson:"version" json:"version"`
+	UpdatedAt     time.Time            `bson:"updated_at" json:"updatedAt"`
+}
+
+type DiscoverySettings struct {
+	Visibility         string `bson:"visibility" json:"visibility"` // who can discover me: everyone, shared_interests, chatted, connections, hidden
+	VisibleInNearby    bool   `bson:"visible_in_nearby" json:"visibleInNearby"`
+	DistanceUnit       string `bson:"distance_unit" json:"distanceUnit"`              // km, mi
+	RecordProfileViews bool   `bson:"record_profile_views" json:"recordProfileViews"` // leave a trace in "who viewed my profile" when viewing others
+}
+
+// NotificationSettings are stored for clients and any future sender: the server itself
+// sends no push or email notifications, so nothing here is read server-side.
+type NotificationSettings struct {
+	Events     map[string][]string `bson:"events" json:"events"` // event -> channels (push, email)
+	QuietHours QuietHours          `bson:"quiet_hours" json:"quietHours"`
+}
+
+// QuietHours is a daily "HH:MM" window in the user's timezone; it may wrap past midnight
+type QuietHours struct {
+	Enabled bool   `bson:"enabled" json:"enabled"`
+	Start   string `bson:"start" json:"start"`
+	End     string `bson:"end" json:"end"`
+}
+
+type ChatSettings struct {
+	WhoCanMessage      string `bson:"who_can_message" json:"whoCanMessage"` // everyone, shared_interests, connections, nobody
+	ReadReceipts       bool   `bson:"read_receipts" json:"readReceipts"`
+	PresenceVisibility string `bson:"presence_visibility" json:"presenceVisibility"` // who sees me online and last seen: everyone, chatted, connections, nobody
+}
+
+// Discovery visibility modes. Hidden users can still browse without being listed.
+const (
+	VisibilityEveryone        = "everyone"
+	VisibilitySharedInterests = "shared_interests"
+	VisibilityChatted         = "chatted"
+	VisibilityConnections     = "connections"
+	VisibilityHidden          = "hidden"
+)
+
+const (
+	DistanceKilometers = "km"
+	DistanceMiles      = "mi"
+
+	MessageEveryone        = "everyone"
+	MessageSharedInterests = "shared_interests"
+	MessageConnections     = "connections"
+	MessageNobody          = "nobody"
+
+	PresenceEveryone    = "everyone"
+	PresenceChatted     = "chatted"
+	PresenceConnections = "connections"
+	PresenceNobody      = "nobody"
+
+	NotifyPush  = "push"
+	NotifyEmail = "email"
+)
+
+// Notification events users can route to channels
+const (
+	EventMeetingRequest  = "meeting_request"
+	EventMeetingResponse = "meeting_response"
+	EventChatMessage     = "chat_message"
+	EventRating          = "rating"
+)
@@ -9,20 +9,70 @@ import (
 type User struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Email             string             `bson:"email" json:"email"`
-	PasswordHash      string             `bson:"password_hash" json:"passwordHash"`
+	PasswordHash      string             `bson:"password_hash" json:"-"`
 	Name              string             `bson:"name" json:"name"`
-	Age               int                `bson:"age" json:"age"`
+	DateOfBirth       *time.Time         `bson:"date_of_birth,omitempty" json:"dateOfBirth,omitempty"` // only shown to the user themselves
+	Age               int                `bson:"-" json:"age,omitempty"`                               // computed from DateOfBirth on read
+	AgePreference     *AgeRange          `bson:"age_preference,omitempty" json:"agePreference,omitempty"`
 	Gender            string             `bson:"gender" json:"gender"`
 	Locality          string             `bson:"locality" json:"locality"`
-	ProfilePictureURL string             `bson:"profile_picture_url" json:"profilePictureUrl"`
+	ProfilePictureURL string             `bson:"-" json:"profilePictureUrl,omitempty"` // the primary photo once approved, set by presentUser
+	Photos            []Photo            `bson:"photos,omitempty" json:"photos"`       // ordered gallery, replaces ProfilePictureURL
 	Bio               string             `bson:"bio" json:"bio"`
 	TrustScore        float64            `bson:"trust_score" json:"trustScore"`
 	UsersRated        int                `bson:"users_rated" json:"usersRated"`
+	ConnectionCount   int                `bson:"connection_count" json:"connectionCount"`
+	MutualConnections *int               `bson:"-" json:"mutualConnections,omitempty"` // shared with the viewer, computed on read
+	InterestMatch     *InterestMatch     `bson:"-" json:"interestMatch,omitempty"`     // how well the user's interests match, on partial matching
+	LegacyRatings     *RatingTally       `bson:"legacy_ratings,omitempty" json:"-"`    // anonymous ratings from before per-rater ratings
 	Verified          bool               `bson:"verified" json:"verified"`
+	EmailVerified     bool               `bson:"email_verified" json:"emailVerified"`      // as reported by Google at login
+	Role              string             `bson:"role,omitempty" json:"-"`                  // empty for regular users, moderator or admin
+	Status            string             `bson:"status,omitempty" json:"status,omitempty"` // active (or empty), suspended, banned
+	SuspendedUntil    *time.Time         `bson:"suspended_until,omitempty" json:"suspendedUntil,omitempty"`
+	SuspensionReason  string             `bson:"suspension_reason,omitempty" json:"suspensionReason,omitempty"`
+	LastSeenAt        *time.Time         `bson:"last_seen_at,omitempty" json:"-"` // last chat socket activity, shown through Presence
+	Presence          *Presence          `bson:"-" json:"presence,omitempty"`     // for viewers the user shares presence with, computed on read
+	Version           int64              `bson:"version" json:"version"`          // incremented on every update, see If-Match
 	CreatedAt         time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt         time.Time          `bson:"updated_at" json:"updatedAt"`
 }
 
+// Presence is whether a user is connected to chat right now and when they were last active
+type Presence struct {
+	Status     string     `json:"status"` // online, away, offline
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// InterestMatch scores a user's interests against the ones being matched: each of the
+// same interests counts 1 and each related interest (see InterestCategory) counts less
+type InterestMatch struct {
+	Exact   int     `json:"exact"`
+	Partial int     `json:"partial"`
+	Score   float64 `json:"score"`
+}
+
+// AgeRange is the inclusive range of ages a user wants to be matched with
+type AgeRange struct {
+	Min int `bson:"min" json:"min"`
+	Max int `bson:"max" json:"max"`
+}
+
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type UserInterest struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	UserID     primitive.ObjectID `bson:"user_id" json:"userId"`
@@ -41,12 +91,15 @@ type Availablility struct {
 
 // MeetingRequest represents a request from one user to meet another during their future availability
 type MeetingRequest struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	RequesterID    primitive.ObjectID `bson:"requester_id" json:"requesterId"`
-	TargetUserID   primitive.ObjectID `bson:"target_user_id" json:"targetUserId"`
-	AvailabilityID primitive.ObjectID `bson:"availability_id" json:"availabilityId"`
-	Message        string             `bson:"message" json:"message"`
-	Status         string             `bson:"status" json:"status"` // pending, accepted, rejected
-	CreatedAt      time.Time          `bson:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time          `bson:"updated_at" json:"updatedAt"`
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	RequesterID    primitive.ObjectID   `bson:"requester_id" json:"requesterId"`
+	TargetUserID   primitive.ObjectID   `bson:"target_user_id" json:"targetUserId"`
+	AvailabilityID primitive.ObjectID   `bson:"availability_id" json:"availabilityId"`
+	Message        string               `bson:"message" json:"message"`
+	Status         string               `bson:"status" json:"status"`                        // pending, accepted, rejected
+	RespondedBy    *primitive.ObjectID  `bson:"responded_by,omitempty" json:"-"`             // who accepted or rejected it; unset on older requests
+	RatedBy        []primitive.ObjectID `bson:"rated_by,omitempty" json:"ratedBy,omitempty"` // participants who rated the other after the meeting
+	Version        int64                `bson:"version" json:"version"`
+	CreatedAt      time.Time            `bson:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time            `bson:"updated_at" json:"updatedAt"`
 }
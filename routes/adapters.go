@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"fast-af/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errCannotParseJSON = errors.New("Cannot parse JSON")
+
+// The adapters below let /api/v2 routes reuse the v1 handlers unchanged: v1 takes the
+// acting user from path params, query params or the body, v2 takes it from the auth token.
+
+// resolveMe rewrites /api/v2/users/me/... to the caller's id and restarts routing
+func resolveMe(c *fiber.Ctx) error {
+	const prefix = "/api/v2/users/me"
+	path := c.Path()
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return c.Next()
+	}
+	caller, _ := middleware.CurrentUserID(c)
+	c.Path("/api/v2/users/" + caller.Hex() + strings.TrimPrefix(path, prefix))
+	return c.RestartRouting()
+}
+
+// selfOnly lets the request through only when the :param path segment is the caller
+func selfOnly(param string, h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, _ := middleware.CurrentUserID(c)
+		if c.Params(param) != caller.Hex() {
+			return c.Status(403).JSON(fiber.Map{"error": "You can only access your own " + strings.TrimSuffix(param, "Id") + " resources"})
+		}
+		return h(c)
+	}
+}
+
+// withCallerQuery fills the v1 query parameter key with the caller's id, overriding any client value
+func withCallerQuery(key string, h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, _ := middleware.CurrentUserID(c)
+		c.Request().URI().QueryArgs().Set(key, caller.Hex())
+		return h(c)
+	}
+}
+
+// withV1Body replaces the request body with the v1 payload returned by build
+func withV1Body(build func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error), h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, _ := middleware.CurrentUserID(c)
+		payload, err := build(c, caller)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Cannot encode request"})
+		}
+		c.Request().SetBody(body)
+		c.Request().Header.SetContentType(fiber.MIMEApplicationJSON)
+		return h(c)
+	}
+}
+
+// parseV2Body decodes the v2 request body into out, reporting a uniform error
+func parseV2Body(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return errCannotParseJSON
+	}
+	return nil
+}
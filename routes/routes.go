@@ -1,21 +1,49 @@
 package routes
 
 import (
+	"encoding/json"
+
+	"fast-af/config"
 	"fast-af/controllers"
+	"fast-af/middleware"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
 func SetupRoutes(app *fiber.App) {
-	api := app.Group("/api/v1")
+	setupV2Routes(app)
+
+	// v1 is frozen: every response advertises its deprecation and the v2 successor
+	api := app.Group("/api/v1", middleware.Deprecated(config.APIV1DeprecatedAt, config.APIV1Sunset, "/api/v2"), middleware.Idempotency)
+
+	// v1 has no tokens: routes that act for a user refuse suspended and banned ones by the
+	// id they act for
+	activeParamUser := middleware.RequireActiveAccount(func(c *fiber.Ctx) string { return c.Params("userId") })
+	activeBodyUser := middleware.RequireActiveAccount(func(c *fiber.Ctx) string {
+		var body struct {
+			UserID string `json:"userId"`
+		}
+		json.Unmarshal(c.Body(), &body)
+		return body.UserID
+	})
+	// v1 chat windows are opened by their first participant
+	activeChatInitiator := middleware.RequireActiveAccount(func(c *fiber.Ctx) string {
+		var body struct {
+			ParticipantIDs []string `json:"participantIds"`
+		}
+		if json.Unmarshal(c.Body(), &body) != nil || len(body.ParticipantIDs) == 0 {
+			return ""
+		}
+		return body.ParticipantIDs[0]
+	})
 
 	// generic routes
 	api.Get("/ping", controllers.Ping)
 
 	// user routes
 	api.Get("/users", controllers.GetUsers)
-	api.Get("/users/:id", controllers.GetUserByID)
+	api.Get("/users/:id", middleware.CacheControl(middleware.CachePrivateRevalidate), controllers.GetUserByID)
 	api.Patch("/users/:userId", controllers.UpdateUserByID)
 	api.Post("/users/:userId/rate", controllers.RateUser)
 
@@ -23,7 +51,7 @@ func SetupRoutes(app *fiber.App) {
 	api.Get("/auth/google/callback", controllers.GoogleCallback)
 
 	// interest routes
-	api.Get("/interests", controllers.GetAllInterests)
+	api.Get("/interests", middleware.CacheControl(middleware.CachePublicShort), controllers.GetAllInterests)
 	api.Post("/interests", controllers.CreateInterest)
 	api.Delete("/interests/:id", controllers.RemoveInterest)
 
@@ -31,7 +59,7 @@ func SetupRoutes(app *fiber.App) {
 	api.Post("/users/interests", controllers.AddUserInterests)
 	api.Delete("/users/interests/:userId/:interestId", controllers.RemoveUserInterest)
 
-	api.Get("/interests/matches/:pattern", controllers.SearchInterests)
+	api.Get("/interests/matches/:pattern", middleware.CacheControl(middleware.CachePublicShort), controllers.SearchInterests)
 
 	// availability routes
 	api.Get("/users/available-now/:userId", controllers.UserAvailableNow)
@@ -39,7 +67,7 @@ func SetupRoutes(app *fiber.App) {
 	api.Post("/users/unset-available-now/:userId", controllers.UnsetAvailableNow)
 
 	// proximity routes
-	api.Post("/users/proximity/:userId", controllers.SetProximityAvailability)
+	api.Post("/users/proximity/:userId", activeParamUser, controllers.SetProximityAvailability)
 	api.Post("/users/proximity/off/:userId", controllers.ToggleProximityOff)
 	api.Patch("/users/proximity/:userId", controllers.UpdateProximityLocation)
 	api.Get("/proximities/active", controllers.GetAllActiveProximities)
@@ -62,15 +90,15 @@ func SetupRoutes(app *fiber.App) {
 	api.Get("/users-match-interests/:userId", controllers.GetUsersByInterests)
 
 	// chat routes (WebSocket and REST fallback)
-	api.Get("/chat/ws/:userId", func(c *fiber.Ctx) error {
+	api.Get("/chat/ws/:userId", activeParamUser, func(c *fiber.Ctx) error {
 		userId := c.Params("userId")
 		chatWindowId := c.Query("chatWindowId")
 		return websocket.New(func(conn *websocket.Conn) {
 			controllers.HandleChatWebSocket(conn, userId, chatWindowId)
 		})(c)
 	})
-	api.Post("/chat/window", controllers.CreateChatWindow)
-	api.Post("/chat/message", controllers.SendMessage)
+	api.Post("/chat/window", activeChatInitiator, controllers.CreateChatWindow)
+	api.Post("/chat/message", activeBodyUser, controllers.SendMessage)
 	api.Delete("/chat/message/:msgId", controllers.DeleteMessage)
 	api.Post("/chat/block", controllers.BlockChat)
 
@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"fast-af/controllers"
+	"fast-af/middleware"
+	"fast-af/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupV2Routes mounts the resource-oriented /api/v2 API. The acting user always comes
+// from the bearer token; "me" can be used in place of the caller's id under /users.
+func setupV2Routes(app *fiber.App) {
+	v2 := app.Group("/api/v2")
+
+	// public routes, registered before the auth middleware
+	v2.Get("/ping", controllers.Ping)
+	v2.Get("/auth/google/login", controllers.GoogleLoginV2)
+	v2.Get("/auth/google/callback", controllers.GoogleCallbackV2)
+	v2.Post("/auth/dev/login", controllers.DevLogin)
+	// media URLs carry their own signature so they work in <img> tags
+	v2.Get("/media/*", controllers.GetMedia)
+
+	// everything registered below requires a bearer token; POSTs honour Idempotency-Key
+	v2.Use(middleware.RequireAuth, resolveMe, middleware.Idempotency)
+
+	v2.Get("/deprecations", middleware.RequireRole(models.RoleAdmin), controllers.GetDeprecatedRouteUsage)
+
+	// user routes
+	v2.Get("/users", controllers.GetUsers)
+	v2.Get("/users/search", controllers.SearchUsers)
+	v2.Get("/users/:id", middleware.CacheControl(middleware.CachePrivateRevalidate), controllers.GetUserByID)
+	v2.Patch("/users/:userId", middleware.RequireIfMatch, selfOnly("userId", controllers.UpdateUserByID))
+	v2.Post("/users/:userId/ratings", withCallerQuery("raterId", controllers.RateUser))
+	v2.Delete("/users/:userId/ratings", withCallerQuery("raterId", controllers.DeleteRating))
+	v2.Get("/users/:userId/reviews", controllers.GetUserReviews)
+	v2.Get("/users/:userId/trust", selfOnly("userId", controllers.GetTrustBreakdown))
+
+	v2.Get("/users/:userId/settings", selfOnly("userId", controllers.GetUserSettings))
+	v2.Patch("/users/:userId/settings", selfOnly("userId", controllers.UpdateUserSettings))
+
+	v2.Get("/users/:userId/blocks", selfOnly("userId", controllers.GetBlockedUsers))
+	v2.Post("/users/:userId/blocks", selfOnly("userId", controllers.BlockUser))
+	v2.Delete("/users/:userId/blocks/:blockedId", selfOnly("userId", controllers.UnblockUser))
+
+	v2.Get("/users/:userId/connections", selfOnly("userId", controllers.GetConnections))
+	v2.Post("/users/:userId/connections", selfOnly("userId", controllers.RequestConnection))
+	v2.Get("/users/:userId/connections/requests", selfOnly("userId", controllers.GetConnectionRequests))
+	v2.Post("/users/:userId/connections/:otherId/accept", selfOnly("userId", controllers.AcceptConnection))
+	v2.Post("/users/:userId/connections/:otherId/decline", selfOnly("userId", controllers.DeclineConnection))
+	v2.Delete("/users/:userId/connections/:otherId", selfOnly("userId", controllers.RemoveConnection))
+	v2.Get("/users/:userId/profile-views", selfOnly("userId", controllers.GetProfileViewers))
+	v2.Get("/users/:userId/profile-views/stats", selfOnly("userId", controllers.GetProfileViewStats))
+	v2.Get("/users/:userId/suggestions", selfOnly("userId", controllers.GetSuggestions))
+
+	// abuse reports; the moderation queue is only open to moderators and admins
+	v2.Post("/reports", controllers.CreateReport)
+	v2.Get("/users/:userId/reports", selfOnly("userId", controllers.GetReportsByUser))
+	v2.Get("/users/:userId/warnings", selfOnly("userId", controllers.GetUserWarnings))
+
+	moderation := v2.Group("/moderation", middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+	moderation.Get("/reports", controllers.GetModerationQueue)
+	moderation.Get("/reports/:reportId", controllers.GetModerationReport)
+	moderation.Post("/reports/:reportId/assign", controllers.AssignReport)
+	moderation.Post("/reports/:reportId/unassign", controllers.UnassignReport)
+	moderation.Post("/reports/:reportId/resolve", controllers.ResolveReport)
+	moderation.Get("/reports/:reportId/audit", controllers.GetReportAudit)
+	moderation.Get("/audit", controllers.GetModerationAudit)
+	moderation.Get("/users/:userId/trust", controllers.GetTrustBreakdown)
+
+	// photo gallery
+	v2.Get("/users/:userId/photos", controllers.GetUserPhotos)
+	v2.Post("/users/:userId/photos", selfOnly("userId", controllers.UploadUserPhoto))
+	v2.Put("/users/:userId/photos/order", selfOnly("userId", controllers.ReorderUserPhotos))
+	v2.Put("/users/:userId/photos/:photoId/primary", selfOnly("userId", controllers.SetPrimaryUserPhoto))
+	v2.Delete("/users/:userId/photos/:photoId", selfOnly("userId", controllers.DeleteUserPhoto))
+
+	// interest routes
+	v2.Get("/interests", middleware.CacheControl(middleware.CachePublicShort), controllers.GetAllInterests)
+	v2.Post("/interests", withV1Body(func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error) {
+		var interest models.Interest
+		if err := parseV2Body(c, &interest); err != nil {
+			return nil, err
+		}
+		interest.CreatedByUserID = caller
+		return interest, nil
+	}, controllers.CreateInterest))
+	v2.Delete("/interests/:id", middleware.RequireRole(models.RoleAdmin), controllers.RemoveInterest)
+	v2.Get("/interests/search", middleware.CacheControl(middleware.CachePublicShort), controllers.SearchInterests)
+	v2.Get("/interest-categories", middleware.CacheControl(middleware.CachePublicShort), controllers.GetInterestCategories)
+	v2.Post("/interest-categories", middleware.RequireRole(models.RoleAdmin), controllers.CreateInterestCategory)
+	v2.Get("/interest-categories/:slug/interests", middleware.CacheControl(middleware.CachePublicShort), controllers.GetCategoryInterests)
+
+	v2.Get("/users/:userId/interests", controllers.GetUserInterests)
+	v2.Post("/users/:userId/interests", selfOnly("userId", withV1Body(func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error) {
+		var body struct {
+			InterestIDs []primitive.ObjectID `json:"interestIds"`
+		}
+		if err := parseV2Body(c, &body); err != nil {
+			return nil, err
+		}
+		userInterests := []models.UserInterest{}
+		for _, id := range body.InterestIDs {
+			userInterests = append(userInterests, models.UserInterest{UserID: caller, InterestID: id})
+		}
+		return userInterests, nil
+	}, controllers.AddUserInterests)))
+	v2.Delete("/users/:userId/interests/:interestId", selfOnly("userId", controllers.RemoveUserInterest))
+
+	// availability routes
+	v2.Get("/users/:userId/availability/now", controllers.UserAvailableNow)
+	v2.Put("/users/:userId/availability/now", selfOnly("userId", controllers.SetAvailableNow))
+	v2.Delete("/users/:userId/availability/now", selfOnly("userId", controllers.UnsetAvailableNow))
+
+	v2.Get("/users/:userId/availabilities", controllers.GetFutureAvailabilityForUser)
+	v2.Post("/users/:userId/availabilities", selfOnly("userId", controllers.SetFutureAvailability))
+	v2.Delete("/users/:userId/availabilities", selfOnly("userId", controllers.CancelFutureAvailability))
+
+	// proximity routes
+	v2.Post("/users/:userId/proximity", selfOnly("userId", controllers.SetProximityAvailability))
+	v2.Patch("/users/:userId/proximity", selfOnly("userId", controllers.UpdateProximityLocation))
+	v2.Delete("/users/:userId/proximity", selfOnly("userId", controllers.ToggleProximityOff))
+	v2.Get("/users/:userId/proximity/nearby", selfOnly("userId", controllers.GetNearbyUsers))
+	v2.Get("/proximities/active", controllers.GetAllActiveProximities)
+
+	// users matching interests
+	v2.Get("/matches", controllers.GetUsersByInterests)
+	v2.Get("/users/:userId/matches", controllers.GetUsersByInterests)
+
+	// meeting request routes
+	v2.Post("/users/:targetUserId/meeting-requests", withCallerQuery("requesterId", controllers.CreateMeetingRequest))
+	v2.Get("/users/:userId/meeting-requests", selfOnly("userId", controllers.GetMeetingRequestsForUser))
+	v2.Get("/users/:userId/meeting-requests/sent", selfOnly("userId", controllers.GetSentMeetingRequestsForUser))
+	v2.Get("/meeting-requests/:id", middleware.CacheControl(middleware.CachePrivateRevalidate), withCallerQuery("userId", controllers.GetMeetingRequest))
+	v2.Patch("/meeting-requests/:id", middleware.RequireIfMatch, withCallerQuery("userId", controllers.UpdateMeetingRequestStatus))
+	v2.Delete("/meeting-requests/:id", withCallerQuery("requesterId", controllers.CancelMeetingRequest))
+
+	// chat routes
+	v2.Get("/chat/ws", func(c *fiber.Ctx) error {
+		caller, _ := middleware.CurrentUserID(c)
+		userId := caller.Hex()
+		chatWindowId := c.Query("chatWindowId")
+		return websocket.New(func(conn *websocket.Conn) {
+			controllers.HandleChatWebSocket(conn, userId, chatWindowId)
+		})(c)
+	})
+	v2.Post("/chat/windows", withV1Body(func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error) {
+		var body struct {
+			ParticipantIDs []string `json:"participantIds"`
+			IsGroup        bool     `json:"isGroup"`
+		}
+		if err := parseV2Body(c, &body); err != nil {
+			return nil, err
+		}
+		// the caller is always a participant of the windows they open
+		participants := []string{caller.Hex()}
+		for _, id := range body.ParticipantIDs {
+			if id != caller.Hex() {
+				participants = append(participants, id)
+			}
+		}
+		body.ParticipantIDs = participants
+		return body, nil
+	}, controllers.CreateChatWindow))
+	v2.Get("/chat/windows/:chatWindowId", middleware.CacheControl(middleware.CachePrivateRevalidate), withCallerQuery("userId", controllers.GetChatWindow))
+	v2.Patch("/chat/windows/:chatWindowId", middleware.RequireIfMatch, withCallerQuery("userId", controllers.UpdateChatWindow))
+	v2.Get("/users/:userId/chat-windows", selfOnly("userId", controllers.GetChatWindowsForUser))
+	v2.Get("/chat/windows/:chatWindowId/messages", withCallerQuery("userId", controllers.GetMessagesForChatWindow))
+	v2.Post("/chat/windows/:chatWindowId/messages", withV1Body(func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error) {
+		var body struct {
+			Msg string `json:"msg"`
+		}
+		if err := parseV2Body(c, &body); err != nil {
+			return nil, err
+		}
+		return fiber.Map{"chatWindowId": c.Params("chatWindowId"), "msg": body.Msg, "userId": caller.Hex()}, nil
+	}, controllers.SendMessage))
+	v2.Post("/chat/windows/:chatWindowId/read", withCallerQuery("userId", controllers.MarkChatWindowRead))
+	v2.Get("/chat/windows/:chatWindowId/reads", withCallerQuery("userId", controllers.GetChatWindowReads))
+	v2.Delete("/chat/messages/:msgId", withCallerQuery("userId", controllers.DeleteMessage))
+	v2.Post("/chat/windows/:chatWindowId/block", withV1Body(func(c *fiber.Ctx, caller primitive.ObjectID) (interface{}, error) {
+		var body struct {
+			RestrictionType string `json:"restrictionType"`
+		}
+		if err := parseV2Body(c, &body); err != nil {
+			return nil, err
+		}
+		return fiber.Map{"chatWindowId": c.Params("chatWindowId"), "restrictedBy": caller.Hex(), "restrictionType": body.RestrictionType}, nil
+	}, controllers.BlockChat))
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3Stub is an in-memory S3 endpoint that insists on signed requests
+func s3Stub(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+			r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+			t.Errorf("%s %s is not signed", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	srv := s3Stub(t)
+	defer srv.Close()
+	s := &S3{Endpoint: srv.URL, Region: "us-east-1", Bucket: "photos", AccessKey: "key", SecretKey: "secret", PathStyle: true, Client: srv.Client()}
+	ctx := context.Background()
+	key := "users/abc/photos/1.jpg"
+
+	if err := s.Put(ctx, key, []byte("jpeg bytes"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	rc, contentType, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "jpeg bytes" || contentType != "image/jpeg" {
+		t.Errorf("got %q (%s), want the stored object", data, contentType)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, key); err != ErrNotFound {
+		t.Errorf("got %v after delete, want ErrNotFound", err)
+	}
+	// deleting a missing object is not an error
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	s := &S3{Endpoint: "https://s3.example.com/", Bucket: "photos"}
+	u, err := s.objectURL("users/a b/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.String(), "https://photos.s3.example.com/users/a%20b/1.jpg"; got != want {
+		t.Errorf("virtual-hosted URL: got %s, want %s", got, want)
+	}
+	s.PathStyle = true
+	u, _ = s.objectURL("users/a b/1.jpg")
+	if got, want := u.String(), "https://s3.example.com/photos/users/a%20b/1.jpg"; got != want {
+		t.Errorf("path-style URL: got %s, want %s", got, want)
+	}
+}
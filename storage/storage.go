@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"fast-af/config"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores uploaded blobs (profile photos and thumbnails) under string keys
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the object's content and its content type
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Files is the configured backend, set up by Setup
+var Files Storage
+
+// Setup selects the backend configured by STORAGE_BACKEND.
+func Setup() {
+	switch config.StorageBackend {
+	case "local":
+		Files = &Local{Root: config.LocalStorageDir}
+	case "s3":
+		if config.S3Endpoint == "" || config.S3Bucket == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET must be set for the s3 storage backend")
+		}
+		region := config.S3Region
+		if region == "" {
+			region = "us-east-1" // what MinIO and most S3-compatible servers expect
+		}
+		Files = &S3{
+			Endpoint:  config.S3Endpoint,
+			Region:    region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			PathStyle: config.S3PathStyle,
+		}
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q", config.StorageBackend)
+	}
+	log.Printf("Storing uploads in %s backend", config.StorageBackend)
+}
@@ -0,0 +1,23 @@
+package utils
+
+import "time"
+
+// AgeAt returns the age in whole years of someone born on dob at the moment now.
+// Birthdays on February 29th fall on March 1st in non-leap years.
+func AgeAt(dob time.Time, now time.Time) int {
+	age := now.Year() - dob.Year()
+	if now.Before(dob.AddDate(age, 0, 0)) {
+		age--
+	}
+	return age
+}
+
+// BornNoLaterThan is the latest date of birth of someone at least age years old at now
+func BornNoLaterThan(age int, now time.Time) time.Time {
+	return now.AddDate(-age, 0, 0)
+}
+
+// BornAfter is the exclusive earliest date of birth of someone at most age years old at now
+func BornAfter(age int, now time.Time) time.Time {
+	return now.AddDate(-(age + 1), 0, 0)
+}
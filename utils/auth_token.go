@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAuthToken = errors.New("invalid auth token")
+var ErrExpiredAuthToken = errors.New("auth token expired")
+
+// IssueAuthToken returns a bearer token of the form <payload>.<signature>
+// where payload is base64url("<userId>|<expiresUnix>").
+func IssueAuthToken(userID string, secret string, ttl time.Duration) string {
+	payload := userID + "|" + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signAuthPayload(encoded, secret)
+}
+
+// ParseAuthToken verifies the signature and expiry of a token and returns the user id it carries.
+func ParseAuthToken(token string, secret string) (string, error) {
+	encoded, sig, found := strings.Cut(token, ".")
+	if !found {
+		return "", ErrInvalidAuthToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(signAuthPayload(encoded, secret))) {
+		return "", ErrInvalidAuthToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidAuthToken
+	}
+	userID, exp, found := strings.Cut(string(raw), "|")
+	if !found {
+		return "", ErrInvalidAuthToken
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidAuthToken
+	}
+	if time.Now().Unix() >= expUnix {
+		return "", ErrExpiredAuthToken
+	}
+	return userID, nil
+}
+
+func signAuthPayload(encoded string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
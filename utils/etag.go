@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// StrongETag builds a quoted strong entity tag from the given parts.
+func StrongETag(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header value lists etag.
+// Comparison is weak (the W/ prefix is ignored) as required for If-None-Match.
+func ETagMatches(header string, etag string) bool {
+	return etagListContains(header, etag, true)
+}
+
+// ETagMatchesStrong reports whether an If-Match header value lists etag.
+// Weak tags never match, as required for If-Match.
+func ETagMatchesStrong(header string, etag string) bool {
+	return etagListContains(header, etag, false)
+}
+
+func etagListContains(header string, etag string, weak bool) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image type, use JPEG or PNG")
+
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// ProcessedImage is a re-encoded upload and its square thumbnail, both JPEG
+type ProcessedImage struct {
+	Data          []byte
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailSize int
+}
+
+// ProcessUploadedImage sniffs the real content type of an upload, applies its EXIF
+// orientation and re-encodes it. Re-encoding drops every metadata block, including
+// EXIF GPS coordinates. Images of more than maxPixels pixels are refused from their
+// header, before anything is decoded, since a small file can declare huge dimensions.
+// The image is scaled to fit maxSide, and a centre-cropped thumbSide x thumbSide
+// thumbnail is produced.
+func ProcessUploadedImage(data []byte, maxSide int, thumbSide int, maxPixels int) (*ProcessedImage, error) {
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg":
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
+	case "image/png":
+		decodeConfig, decode = png.DecodeConfig, png.Decode
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxPixels/cfg.Height {
+		return nil, ErrImageTooLarge
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, JPEGOrientation(data))
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			w, h = maxSide, max(1, h*maxSide/b.Dx())
+		} else {
+			w, h = max(1, w*maxSide/b.Dy()), maxSide
+		}
+	}
+	full := resizeImage(img, b, w, h)
+
+	// centre crop to a square before scaling down
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2, 0, 0)
+	crop.Max = crop.Min.Add(image.Pt(side, side))
+	thumb := resizeImage(img, crop, min(thumbSide, side), min(thumbSide, side))
+
+	out := &ProcessedImage{Width: w, Height: h, ThumbnailSize: thumb.Bounds().Dx()}
+	if out.Data, err = encodeJPEG(full); err != nil {
+		return nil, err
+	}
+	if out.Thumbnail, err = encodeJPEG(thumb); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeImage box-samples the src rectangle of img into a w x h image, flattening
+// transparency onto white since the output is JPEG. Source rows are flattened one
+// strip of output height at a time, so memory stays proportional to the width.
+func resizeImage(img image.Image, src image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Dx(), src.Dy()
+	strip := image.NewRGBA(image.Rect(0, 0, sw, sh/h+1))
+	white := image.NewUniform(color.White)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		rows := image.Rect(0, 0, sw, y1-y0)
+		draw.Draw(strip, rows, white, image.Point{}, draw.Src)
+		draw.Draw(strip, rows, img, image.Pt(src.Min.X, src.Min.Y+y0), draw.Over)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, b, n uint32
+			for sy := 0; sy < y1-y0; sy++ {
+				row := strip.Pix[sy*strip.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4:]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xFF
+		}
+	}
+	return dst
+}
+
+// applyOrientation rotates/flips img so it displays upright without EXIF
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // 5-8 swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
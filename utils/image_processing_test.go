@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader is a PNG signature and IHDR chunk declaring w x h, with no image data
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA
+	chunk := append([]byte("IHDR"), ihdr...)
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = binary.BigEndian.AppendUint32(out, uint32(len(ihdr)))
+	out = append(out, chunk...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(chunk))
+}
+
+func TestProcessUploadedImageRefusesHugeDimensions(t *testing.T) {
+	_, err := ProcessUploadedImage(pngHeader(100000, 100000), 2048, 320, 40_000_000)
+	if err != ErrImageTooLarge {
+		t.Fatalf("got %v, want ErrImageTooLarge", err)
+	}
+	// just over the limit
+	_, err = ProcessUploadedImage(pngHeader(201, 100), 2048, 320, 20_000)
+	if err != ErrImageTooLarge {
+		t.Fatalf("got %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestProcessUploadedImageRefusesOtherTypes(t *testing.T) {
+	_, err := ProcessUploadedImage([]byte("GIF89a not really"), 2048, 320, 40_000_000)
+	if err != ErrUnsupportedImage {
+		t.Fatalf("got %v, want ErrUnsupportedImage", err)
+	}
+}
+
+func TestProcessUploadedImageResizes(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			if x < 200 {
+				src.Set(x, y, color.NRGBA{R: 255, A: 255})
+			} // the right half stays transparent
+		}
+	}
+	out, err := ProcessUploadedImage(encodePNG(t, src), 100, 50, 40_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Width != 100 || out.Height != 50 {
+		t.Errorf("got %dx%d, want 100x50", out.Width, out.Height)
+	}
+	if out.ThumbnailSize != 50 {
+		t.Errorf("got thumbnail size %d, want 50", out.ThumbnailSize)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 50 {
+		t.Errorf("encoded image is %v, want 100x50", img.Bounds())
+	}
+	// transparency is flattened onto white; allow for JPEG artefacts
+	r, g, b, _ := img.At(10, 25).RGBA()
+	if r>>8 < 230 || g>>8 > 40 || b>>8 > 40 {
+		t.Errorf("left half is %d,%d,%d, want red", r>>8, g>>8, b>>8)
+	}
+	r, g, b, _ = img.At(90, 25).RGBA()
+	if r>>8 < 230 || g>>8 < 230 || b>>8 < 230 {
+		t.Errorf("right half is %d,%d,%d, want white", r>>8, g>>8, b>>8)
+	}
+}
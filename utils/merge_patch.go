@@ -0,0 +1,23 @@
+package utils
+
+// MergePatch applies an RFC 7396 JSON merge patch to target, both as decoded by
+// encoding/json. Objects are merged recursively, null removes a member and any other
+// value replaces the target. target may be modified in place.
+func MergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+			continue
+		}
+		targetObj[name] = MergePatch(targetObj[name], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "strings"
+
+// Slugify turns a display name into a lowercase slug of ASCII letters, digits and single
+// dashes, e.g. "Food & Drink" becomes "food-drink". It is empty if nothing is left.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}